@@ -0,0 +1,98 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/preflight"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// Mode - backup type
+type Mode string
+
+const (
+	// ModeRsync - file based backup into a directory, unchanged files are hardlinked to the previous backup
+	ModeRsync Mode = "rsync"
+	// ModeTar - file based backup into a compressed tar file
+	ModeTar Mode = "tar"
+	// ModeDD - image backup of a device into a compressed image file
+	ModeDD Mode = "dd"
+	// ModeSnapshot - file based backup of a read only snapshot of a btrfs subvolume into a directory
+	ModeSnapshot Mode = "snapshot"
+)
+
+// Options -
+type Options struct {
+	Mode     Mode
+	Source   string // directory for rsync and tar, device for dd
+	Target   string // directory for rsync, file for tar and dd
+	LinkDest string // rsync only: previous backup directory used for hardlinks
+	Send     bool   // snapshot only: btrfs send into a btrfs target instead of rsync
+	Parent   string // snapshot only: parent snapshot of an incremental btrfs send, default is the previous snapshot
+}
+
+func (o Options) String() string {
+	return fmt.Sprintf("Mode: %s Source: %s Target: %s LinkDest: %s Send: %t Parent: %s", o.Mode, o.Source, o.Target, o.LinkDest, o.Send, o.Parent)
+}
+
+// ParseMode -
+func ParseMode(mode string) (Mode, error) {
+	switch m := Mode(strings.ToLower(mode)); m {
+	case ModeRsync, ModeTar, ModeDD, ModeSnapshot:
+		return m, nil
+	}
+	return "", fmt.Errorf("Invalid backup mode %s", mode)
+}
+
+// Run - creates the backup. The backup is refused if a tool required for the mode is missing or if a single raid
+// member would be read or written. Inconsistent metadata of the source disk is reported. The LUKS header of an
+// encrypted source is stored next to the backup, see LuksHeaderFile
+func Run(ctx context.Context, options Options) error {
+
+	tools.Logger.Debugf("Starting backup %s", options)
+
+	if _, err := ParseMode(string(options.Mode)); err != nil {
+		return err
+	}
+	if err := preflight.Require(ctx, preflight.Mode(options.Mode)); err != nil {
+		return err
+	}
+
+	if err := checkRaid(ctx, options); err != nil {
+		return err
+	}
+	checkConsistency(ctx, options)
+
+	// a backup without LUKS header is still usable if the header of the container is intact
+	container, err := luksContainer(ctx, options)
+	if err != nil {
+		tools.Logger.Warnf("LUKS container of %s not detected: %s", options.Source, err.Error())
+	}
+	if container != "" {
+		if err := preflight.Require(ctx, preflight.ModeLuks); err != nil {
+			return err
+		}
+	}
+	if options.Mode == ModeDD && container != "" && container != options.Source {
+		tools.Logger.Infof("Creating image of LUKS container %s of %s", container, options.Source)
+		options.Source = container
+	}
+
+	if err := run(ctx, options); err != nil {
+		return err
+	}
+	if container != "" {
+		return backupLuksHeader(ctx, container, options.Target)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/preflight"
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRefused(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	previous := preflight.LookPath
+	defer func() { preflight.LookPath = previous }()
+	preflight.LookPath = func(name string) (string, error) {
+		return "", fmt.Errorf("%s not found", name)
+	}
+
+	dir, err := ioutil.TempDir("", "raspiBackupTest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "backup.tgz")
+	err = Run(context.Background(), Options{Mode: ModeTar, Source: dir, Target: target})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tar: not installed")
+	_, err = os.Stat(target)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = ParseMode("tape")
+	assert.Error(t, err)
+
+	// restores require the tools of their mode and cryptsetup for LUKS headers only
+	err = Restore(context.Background(), RestoreOptions{Mode: ModeRsync, Source: dir, Target: target})
+	assert.EqualError(t, err, "Preflight check failed: rsync: not installed")
+	err = Restore(context.Background(), RestoreOptions{Mode: ModeDD, Source: target, Target: "/dev/sda", Header: target + LuksHeaderSuffix})
+	assert.EqualError(t, err, "Preflight check failed: cryptsetup: not installed, dd: not installed, gzip: not installed")
+}
+
+func TestBackupDryRun(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	if _, err := preflight.LookPath("tar"); err != nil {
+		t.Skip("tar not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "raspiBackupTest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	commands.SetDryRun(true, ioutil.Discard)
+	defer commands.SetDryRun(false, os.Stdout)
+
+	target := filepath.Join(dir, "backup.tgz")
+	assert.NoError(t, Run(context.Background(), Options{Mode: ModeTar, Source: dir, Target: target}))
+	_, err = os.Stat(target)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLuksHeader(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	replayer, err := commands.NewReplayRunner("../commands/testData/replay_test/luks")
+	assert.NoError(t, err)
+	defer commands.SetRunner(commands.SetRunner(replayer))
+
+	ctx := context.Background()
+	tests := []struct {
+		options   Options
+		container string
+	}{
+		{Options{Mode: ModeDD, Source: "/dev/mmcblk0p2"}, "/dev/mmcblk0p2"},
+		{Options{Mode: ModeDD, Source: "/dev/mapper/cryptroot"}, "/dev/mmcblk0p2"},
+		{Options{Mode: ModeDD, Source: "/dev/mmcblk0p1"}, ""},
+		{Options{Mode: ModeTar, Source: "/home/pi"}, "/dev/mmcblk0p2"},
+		{Options{Mode: ModeRsync, Source: "/boot/firmware"}, ""},
+	}
+	for _, test := range tests {
+		container, err := luksContainer(ctx, test.options)
+		assert.NoError(t, err)
+		assert.Equal(t, test.container, container, test.options.String())
+	}
+
+	assert.Equal(t, "/backup/rootfs.luksheader", LuksHeaderFile("/backup/rootfs/"))
+	assert.NoError(t, backupLuksHeader(ctx, "/dev/mmcblk0p2", "/backup/rootfs.tgz"))
+	assert.NoError(t, backupLuksHeader(ctx, "/dev/mmcblk0p2", "/backup/rootfs.img"))
+
+	err = Restore(ctx, RestoreOptions{Mode: ModeTar, Source: "/backup/rootfs.tgz", Target: "/mnt", Header: "/backup/rootfs.img.luksheader"})
+	assert.Error(t, err)
+}
+
+func TestCheckRaid(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	replayer, err := commands.NewReplayRunner("../commands/testData/replay_test/raid")
+	assert.NoError(t, err)
+	defer commands.SetRunner(commands.SetRunner(replayer))
+
+	tests := []struct {
+		options Options
+		refused bool
+	}{
+		{Options{Mode: ModeDD, Source: "/dev/sda1", Target: "/backup/nas.img"}, true},
+		{Options{Mode: ModeDD, Source: "/dev/sdb", Target: "/backup/nas.img"}, true},
+		{Options{Mode: ModeDD, Source: "/dev/md0", Target: "/backup/nas.img"}, false},
+		{Options{Mode: ModeDD, Source: "/dev/mmcblk0", Target: "/srv/nas/pi.img"}, false},
+		{Options{Mode: ModeTar, Source: "/srv/nas/photos", Target: "/backup/photos.tgz"}, false},
+		{Options{Mode: ModeRsync, Source: "/", Target: "/srv/nas/backup"}, false},
+	}
+	for _, test := range tests {
+		err := checkRaid(context.Background(), test.options)
+		if test.refused {
+			assert.Error(t, err, test.options.String())
+		} else {
+			assert.NoError(t, err, test.options.String())
+		}
+	}
+
+	err = checkRaid(context.Background(), Options{Mode: ModeDD, Source: "/dev/sda1"})
+	assert.EqualError(t, err, "Backup source /dev/sda1 is a member of raid array /dev/md0, use /dev/md0 instead")
+}
+
+func TestSnapshot(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	replayer, err := commands.NewReplayRunner("../commands/testData/replay_test/btrfs")
+	assert.NoError(t, err)
+	defer commands.SetRunner(commands.SetRunner(replayer))
+
+	previousNow := now
+	defer func() { now = previousNow }()
+	now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+
+	var output bytes.Buffer
+	commands.SetDryRun(true, &output)
+	defer commands.SetDryRun(false, os.Stdout)
+
+	ctx := context.Background()
+	assert.NoError(t, snapshot(ctx, Options{Mode: ModeSnapshot, Source: "/home", Target: "/backup/home"}))
+	assert.Contains(t, output.String(), "btrfs subvolume snapshot -r /home /home/.raspiBackup-20261018-120000")
+	assert.Contains(t, output.String(), "/home/.raspiBackup-20261018-120000/ /backup/home")
+	assert.Contains(t, output.String(), "btrfs subvolume delete /home/.raspiBackup-20261018-120000")
+
+	output.Reset()
+	parent := "/home/.raspiBackup-20261017-120000"
+	assert.NoError(t, snapshot(ctx, Options{Mode: ModeSnapshot, Source: "/home", Target: "/.snapshots/home", Send: true, Parent: parent}))
+	assert.Contains(t, output.String(), "btrfs send -p "+parent+" /home/.raspiBackup-20261018-120000 |")
+	assert.Contains(t, output.String(), "btrfs receive /.snapshots/home")
+	assert.NotContains(t, output.String(), "delete")
+
+	err = snapshot(ctx, Options{Mode: ModeSnapshot, Source: "/boot/firmware", Target: "/backup/boot"})
+	assert.EqualError(t, err, "Snapshot backup source /boot/firmware is no mounted btrfs subvolume")
+}
+
+func TestCheckConsistency(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	// blkid reports label BOOTFS but lsblk bootfs for /dev/mmcblk0p1
+	replayer, err := commands.NewReplayRunner("../commands/testData/replay_test/inconsistent")
+	assert.NoError(t, err)
+	defer commands.SetRunner(commands.SetRunner(replayer))
+
+	var buffer bytes.Buffer
+	warnings = &buffer
+	defer func() { warnings = os.Stderr }()
+
+	tests := []struct {
+		options Options
+		warned  bool
+	}{
+		{Options{Mode: ModeDD, Source: "/dev/mmcblk0p1", Target: "/backup/boot.img"}, true},
+		{Options{Mode: ModeRsync, Source: "/", Target: "/backup"}, true}, // root is on a LUKS container on /dev/mmcblk0p2
+		{Options{Mode: ModeDD, Source: "/dev/sda", Target: "/backup/sda.img"}, false},
+	}
+	for _, test := range tests {
+		buffer.Reset()
+		checkConsistency(context.Background(), test.options)
+		if test.warned {
+			assert.Equal(t, fmt.Sprintf("WARNING: Inconsistent metadata of backup source %s: /dev/mmcblk0p1: Label is BOOTFS from blkid but bootfs from lsblk\n",
+				test.options.Source), buffer.String())
+		} else {
+			assert.Empty(t, buffer.String(), test.options.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/model"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// warnings - receives the warnings about inconsistent metadata of the backup source disk
+var warnings io.Writer = os.Stderr
+
+// sourceDisk - disk of the backup source, e.g. mmcblk0 for /dev/mmcblk0p2 or for a directory on a LUKS container on
+// /dev/mmcblk0p2. Empty if the disk is not known, e.g. for raid arrays
+func sourceDisk(discovery *model.Discovery, options Options) string {
+
+	device := options.Source
+	if options.Mode != ModeDD {
+		if discovery.MountTable == nil {
+			return ""
+		}
+		table := discovery.MountTable
+		device = discovery.Devices.Partition(table.Device(table.Containing(options.Source), discovery.Devices))
+	}
+	name, err := commands.ParseDeviceName(device)
+	if err != nil {
+		return ""
+	}
+	return name.Disk
+}
+
+// checkConsistency - warns loudly about inconsistent metadata of the disk of the backup source, e.g. a partition
+// reported by lsblk but not by parted. The backup is not refused because the backup tools don't use the metadata
+func checkConsistency(ctx context.Context, options Options) {
+
+	discovery, err := model.Discover(ctx, model.CommandProviders(), true)
+	if err != nil {
+		tools.Logger.Warnf("Discovery of backup source %s incomplete: %s", options.Source, err.Error())
+	}
+	if discovery.System == nil {
+		return
+	}
+
+	disk := sourceDisk(discovery, options)
+	for _, d := range discovery.System.Disks {
+		if disk == "" || strings.TrimPrefix(d.Name, "/dev/") != disk {
+			continue
+		}
+		for _, warning := range d.Warnings {
+			tools.Logger.Warnf("Inconsistent metadata of backup source %s: %s", options.Source, warning)
+			fmt.Fprintf(warnings, "WARNING: Inconsistent metadata of backup source %s: %s\n", options.Source, warning)
+		}
+	}
+}
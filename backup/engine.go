@@ -0,0 +1,78 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+)
+
+// run - creates the backup of the mode, the source is checked by Run
+func run(ctx context.Context, options Options) error {
+	switch options.Mode {
+	case ModeRsync:
+		return rsync(ctx, options)
+	case ModeSnapshot:
+		return snapshot(ctx, options)
+	case ModeTar:
+		return toFile(commands.NewPipeline(
+			commands.NewCommandContext(ctx, commands.TypeSudo, "tar", "-cpf", "-", "--one-file-system", "--numeric-owner", "-C", options.Source, ".").InheritEnvironment(),
+			commands.NewCommandContext(ctx, commands.TypeNormal, "gzip", "-c").InheritEnvironment()), options.Target)
+	default:
+		return toFile(commands.NewPipeline(
+			commands.NewCommandContext(ctx, commands.TypeSudo, "dd", "if="+options.Source, "bs=1M").InheritEnvironment(),
+			commands.NewCommandContext(ctx, commands.TypeNormal, "gzip", "-c").InheritEnvironment()), options.Target)
+	}
+}
+
+// rsync - copies the source into the target directory, unchanged files are hardlinked to LinkDest
+func rsync(ctx context.Context, options Options) error {
+	args := []string{"-aHAXx", "--numeric-ids", "--delete"}
+	if options.LinkDest != "" {
+		args = append(args, "--link-dest="+options.LinkDest)
+	}
+	args = append(args, strings.TrimSuffix(options.Source, "/")+"/", options.Target)
+	_, err := commands.NewCommandContext(ctx, commands.TypeSudo, "rsync", args...).InheritEnvironment().Execute()
+	return err
+}
+
+// toFile - writes the output of the pipeline into a new file which is removed if the pipeline fails
+func toFile(pipeline *commands.Pipeline, target string) error {
+
+	if commands.DryRun() {
+		pipeline.Stdout = ioutil.Discard
+		stream, err := pipeline.Start()
+		if err != nil {
+			return err
+		}
+		return stream.Wait()
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	pipeline.Stdout = f
+
+	stream, err := pipeline.Start()
+	if err == nil {
+		err = stream.Wait()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+	}
+	return err
+}
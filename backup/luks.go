@@ -0,0 +1,90 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// LuksHeaderSuffix - suffix of the LUKS header backup stored next to the backup
+const LuksHeaderSuffix = ".luksheader"
+
+// LuksHeaderFile - file of the LUKS header backup of a backup file or directory
+func LuksHeaderFile(target string) string {
+	return strings.TrimSuffix(target, "/") + LuksHeaderSuffix
+}
+
+// openMapping - status of the open LUKS mapping of a device, e.g. /dev/mapper/cryptroot, or nil
+func openMapping(ctx context.Context, device string) (*commands.CryptStatus, error) {
+
+	if !strings.HasPrefix(device, "/dev/mapper/") {
+		return nil, nil
+	}
+	status, err := commands.NewCryptStatus(ctx, strings.TrimPrefix(device, "/dev/mapper/"))
+	switch {
+	case commands.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		var commandError *commands.CommandError
+		if errors.As(err, &commandError) && commandError.ExitCode == 4 { // no dm-crypt mapping, e.g. a logical volume
+			return nil, nil
+		}
+		return nil, err
+	case !strings.HasPrefix(status.Type, "LUKS"):
+		return nil, nil
+	}
+	return status, nil
+}
+
+// luksContainer - LUKS container of the backup source. An image backup of an open mapping uses the raw container, file
+// based backups use the container of the filesystem containing the source. Empty if the source isn't encrypted
+func luksContainer(ctx context.Context, options Options) (string, error) {
+
+	if options.Mode == ModeDD {
+		status, err := openMapping(ctx, options.Source)
+		if err != nil {
+			return "", err
+		}
+		if status != nil {
+			return status.Device, nil
+		}
+		isLuks, err := commands.IsLuks(ctx, options.Source)
+		if err != nil || !isLuks {
+			return "", err
+		}
+		return options.Source, nil
+	}
+
+	table, err := commands.NewMountTable(ctx)
+	if err != nil {
+		return "", err
+	}
+	mount := table.Containing(options.Source)
+	if mount == nil {
+		return "", nil
+	}
+	status, err := openMapping(ctx, mount.Source)
+	if err != nil || status == nil {
+		return "", err
+	}
+	return status.Device, nil
+}
+
+// backupLuksHeader - stores the LUKS header of the container next to the backup. The header and the key slots are
+// required to restore an image on a new card or to open a container whose header got damaged
+func backupLuksHeader(ctx context.Context, container, target string) error {
+	header := LuksHeaderFile(target)
+	tools.Logger.Infof("Saving LUKS header of %s in %s", container, header)
+	return commands.LuksHeaderBackup(ctx, container, header)
+}
@@ -0,0 +1,87 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// mdMajor - major number of md arrays, the minor is the number of the array, e.g. 9:127 for md127
+const mdMajor = "9"
+
+// mountDevice - device name of a mount, e.g. md0 or sda1
+func mountDevice(m *sysfs.Mount) string {
+	if parts := strings.SplitN(m.Dev, ":", 2); len(parts) == 2 && parts[0] == mdMajor {
+		return "md" + parts[1]
+	}
+	return strings.TrimPrefix(m.Source, "/dev/")
+}
+
+// isMember - device is a member of the array or a disk with a member partition
+func isMember(array *sysfs.MdArray, device string) bool {
+	for _, m := range array.Members {
+		if m.Name == device {
+			return true
+		}
+		if name, err := commands.ParseDeviceName(m.Name); err == nil && name.Disk == device {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRaid - refuses backups which read from or write to a single raid member because this bypasses the array and
+// breaks the mirror. Degraded arrays are reported
+func checkRaid(ctx context.Context, options Options) error {
+
+	arrays, err := commands.NewMdstat(ctx)
+	if err != nil {
+		tools.Logger.Warnf("Raid arrays not detected: %s", err.Error())
+		return nil
+	}
+	if len(arrays) == 0 {
+		return nil
+	}
+
+	devices := map[string]string{} // device -> backup source or target
+	if options.Mode == ModeDD {
+		devices[strings.TrimPrefix(options.Source, "/dev/")] = "source"
+	}
+	if table, err := commands.NewMountTable(ctx); err == nil {
+		paths := map[string]string{"target": options.Target}
+		if options.Mode != ModeDD {
+			paths["source"] = options.Source
+		}
+		for role, path := range paths {
+			if m := table.Containing(path); m != nil && path != "" {
+				devices[mountDevice(m)] = role
+			}
+		}
+	} else {
+		tools.Logger.Warnf("Raid arrays of backup source and target not detected: %s", err.Error())
+	}
+
+	for _, a := range arrays {
+		for device, role := range devices {
+			switch {
+			case isMember(a, device):
+				return fmt.Errorf("Backup %s /dev/%s is a member of raid array /dev/%s, use /dev/%s instead", role, device, a.Name, a.Name)
+			case device == a.Name && a.Degraded():
+				tools.Logger.Warnf("Raid array /dev/%s of backup %s is degraded: %s", a.Name, role, strings.TrimSpace(a.Status+" "+a.Sync))
+			}
+		}
+	}
+	return nil
+}
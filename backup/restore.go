@@ -0,0 +1,97 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/preflight"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// RestoreOptions -
+type RestoreOptions struct {
+	Mode   Mode
+	Source string // backup directory for rsync, backup file for tar and dd
+	Target string // directory for rsync and tar, e.g. the mountpoint of an open LUKS mapping, device for dd
+	Header string // dd only: LUKS header backup written to the device after the image, see LuksHeaderFile
+}
+
+func (o RestoreOptions) String() string {
+	return fmt.Sprintf("Mode: %s Source: %s Target: %s Header: %s", o.Mode, o.Source, o.Target, o.Header)
+}
+
+// Restore - restores a backup. Encrypted filesystems are restored file based into the mountpoint of the open mapping.
+// The LUKS header of an image is recreated from the header backup because the container may have been reformatted
+// with a new header and volume key since the backup was created
+func Restore(ctx context.Context, options RestoreOptions) error {
+
+	tools.Logger.Debugf("Starting restore %s", options)
+
+	if _, err := ParseMode(string(options.Mode)); err != nil {
+		return err
+	}
+	if options.Mode == ModeSnapshot {
+		return fmt.Errorf("Backups of mode %s are restored with mode %s", ModeSnapshot, ModeRsync)
+	}
+	if options.Header != "" && options.Mode != ModeDD {
+		return fmt.Errorf("LUKS header %s can be restored with an image only", options.Header)
+	}
+	modes := []preflight.Mode{preflight.Mode("restore-" + string(options.Mode))}
+	if options.Header != "" {
+		modes = append(modes, preflight.ModeLuks)
+	}
+	if err := preflight.Require(ctx, modes...); err != nil {
+		return err
+	}
+
+	switch options.Mode {
+	case ModeRsync:
+		_, err := commands.NewCommandContext(ctx, commands.TypeSudo, "rsync", "-aHAXx", "--numeric-ids", "--delete",
+			strings.TrimSuffix(options.Source, "/")+"/", options.Target).InheritEnvironment().Execute()
+		return err
+	case ModeTar:
+		return fromFile(commands.NewPipeline(
+			commands.NewCommandContext(ctx, commands.TypeNormal, "gzip", "-dc").InheritEnvironment(),
+			commands.NewCommandContext(ctx, commands.TypeSudo, "tar", "-xpf", "-", "--numeric-owner", "-C", options.Target).InheritEnvironment()), options.Source)
+	}
+
+	if err := fromFile(commands.NewPipeline(
+		commands.NewCommandContext(ctx, commands.TypeNormal, "gzip", "-dc").InheritEnvironment(),
+		commands.NewCommandContext(ctx, commands.TypeSudo, "dd", "of="+options.Target, "bs=1M").InheritEnvironment()), options.Source); err != nil {
+		return err
+	}
+	if options.Header == "" {
+		return nil
+	}
+	tools.Logger.Infof("Restoring LUKS header of %s from %s", options.Target, options.Header)
+	return commands.LuksHeaderRestore(ctx, options.Target, options.Header)
+}
+
+// fromFile - feeds the backup file into the pipeline
+func fromFile(pipeline *commands.Pipeline, source string) error {
+
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pipeline.Stdin, pipeline.Stdout = f, ioutil.Discard
+	stream, err := pipeline.Start()
+	if err != nil {
+		return err
+	}
+	return stream.Wait()
+}
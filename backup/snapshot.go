@@ -0,0 +1,108 @@
+package backup
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// SnapshotPrefix - prefix of the snapshots created in the source subvolume. Snapshots are nested subvolumes and
+// therefore not part of later snapshots of the source
+const SnapshotPrefix = ".raspiBackup-"
+
+const snapshotTimeFormat = "20060102-150405"
+
+// now - time used for snapshot names
+var now = time.Now
+
+// previousSnapshot - latest snapshot in source which was received in target already, or empty
+func previousSnapshot(source, target, current string) string {
+
+	entries, err := ioutil.ReadDir(source)
+	if err != nil {
+		return ""
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), SnapshotPrefix) && e.Name() != current {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(target, name)); err == nil {
+			return filepath.Join(source, name)
+		}
+	}
+	return ""
+}
+
+// snapshot - creates a read only snapshot of the source subvolume and backs it up with rsync. The snapshot is
+// deleted afterwards. With btrfs send the snapshot is sent into the target and kept as parent of the next backup,
+// the previous snapshot is deleted instead
+func snapshot(ctx context.Context, options Options) error {
+
+	table, err := commands.NewMountTable(ctx)
+	if err != nil {
+		return err
+	}
+	source := filepath.Clean(options.Source)
+	if m := table.Find(source); m == nil || m.FSType != "btrfs" {
+		return fmt.Errorf("Snapshot backup source %s is no mounted btrfs subvolume", source)
+	}
+	if options.Send {
+		if m := table.Containing(options.Target); m == nil || m.FSType != "btrfs" {
+			return fmt.Errorf("btrfs send requires a btrfs backup target, %s is no btrfs filesystem", options.Target)
+		}
+	}
+
+	name := SnapshotPrefix + now().Format(snapshotTimeFormat)
+	snapshot := filepath.Join(source, name)
+	tools.Logger.Infof("Creating snapshot %s", snapshot)
+	if err := commands.BtrfsSnapshot(ctx, source, snapshot); err != nil {
+		return err
+	}
+
+	if !options.Send {
+		err := rsync(ctx, Options{Source: snapshot, Target: options.Target, LinkDest: options.LinkDest})
+		if deleteErr := commands.BtrfsDelete(ctx, snapshot); err == nil {
+			err = deleteErr
+		}
+		return err
+	}
+
+	parent := options.Parent
+	if parent == "" {
+		parent = previousSnapshot(source, options.Target, name)
+	}
+	tools.Logger.Infof("Sending snapshot %s with parent %s to %s", snapshot, parent, options.Target)
+	if err := commands.BtrfsSend(ctx, snapshot, parent, options.Target); err != nil {
+		if deleteErr := commands.BtrfsDelete(ctx, snapshot); deleteErr != nil {
+			tools.Logger.Warnf("Snapshot %s not deleted: %s", snapshot, deleteErr.Error())
+		}
+		return err
+	}
+
+	// snapshots passed with -parent are not created by raspiBackup and kept
+	if parent != "" && options.Parent == "" {
+		return commands.BtrfsDelete(ctx, parent)
+	}
+	return nil
+}
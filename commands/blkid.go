@@ -11,13 +11,12 @@ package commands
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/framps/raspiBackupNext/tools"
@@ -25,27 +24,54 @@ import (
 
 // BlkidPartition -
 type BlkidPartition struct {
-	Number   int
-	Uuid     string
-	Type     string
-	Partuuid string
-	Label    string
-	Pttype   string
+	Number    int
+	Uuid      string
+	Type      string
+	Partuuid  string
+	Label     string
+	Pttype    string
+	SecType   string // msdos for vfat
+	BlockSize string // filesystem block size, reported since util-linux 2.35
+	Partlabel string // gpt partition name
 }
 
 func (b BlkidPartition) String() string {
-	return fmt.Sprintf("Partition: %d Uuid: %40s Type: %6s Partuuid: %6s Pttype: %6s Label: %6s", b.Number, b.Uuid, b.Type, b.Partuuid, b.Pttype, b.Label)
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("Partition: %d Uuid: %40s Type: %6s Partuuid: %6s Pttype: %6s Label: %6s", b.Number, b.Uuid, b.Type, b.Partuuid, b.Pttype, b.Label))
+	if b.SecType != "" {
+		result.WriteString(fmt.Sprintf(" SecType: %s", b.SecType))
+	}
+	if b.BlockSize != "" {
+		result.WriteString(fmt.Sprintf(" BlockSize: %s", b.BlockSize))
+	}
+	if b.Partlabel != "" {
+		result.WriteString(fmt.Sprintf(" Partlabel: %s", b.Partlabel))
+	}
+	return result.String()
 }
 
 // BlkidDisk -
 type BlkidDisk struct {
 	Name       string // e.g. /dev/sda
 	Partitions map[int]*BlkidPartition
+
+	// whole disk, e.g. partition table or lvm physical volume without partition table
+	Uuid   string
+	Type   string
+	Label  string
+	Pttype string
+	Ptuuid string
 }
 
 func (b BlkidDisk) String() string {
 	var result bytes.Buffer
-	result.WriteString(fmt.Sprintf("Disk: %s\n", b.Name))
+	result.WriteString(fmt.Sprintf("Disk: %s", b.Name))
+	for _, kv := range [][2]string{{"Uuid", b.Uuid}, {"Type", b.Type}, {"Label", b.Label}, {"Pttype", b.Pttype}, {"Ptuuid", b.Ptuuid}} {
+		if kv[1] != "" {
+			result.WriteString(fmt.Sprintf(" %s: %s", kv[0], kv[1]))
+		}
+	}
+	result.WriteString("\n")
 
 	index := make([]*BlkidPartition, 0, len(b.Partitions))
 
@@ -88,13 +114,13 @@ func (b *BlkidDisks) String() string {
 }
 
 // NewBlkidDisks -
-func NewBlkidDisks() (*BlkidDisks, error) {
+func NewBlkidDisks(ctx context.Context) (*BlkidDisks, error) {
 
 	logger := tools.Logger
 
 	blkid := BlkidDisks{make(map[string]*BlkidDisk, 16)}
 
-	command := NewCommand(TypeSudo, "blkid")
+	command := NewCommandContext(ctx, TypeSudo, "blkid", "-o", "export")
 	result, err := command.Execute()
 	if err != nil {
 		logger.Errorf("NewBlkid failed: %s", err.Error())
@@ -114,63 +140,166 @@ func NewBlkidPartition(partitionNumber int) *BlkidPartition {
 }
 
 /*
+blkid:
+
 /dev/sda1: UUID="96ad35d1-85b1-45c8-941d-5c06e2ccc3c4" TYPE="ext4" PARTUUID="1de6ca19-01"
 /dev/sdb1: UUID="dFRcFX-d3bX-y9Pp-Hts3-vPyK-hhcL-lAluXv" TYPE="LVM2_member" PARTUUID="6c96114a-01"
 /dev/sdc: UUID="3s8MZp-Dxcd-UExv-JyGC-bXNG-Os8Q-mwqVpP" TYPE="LVM2_member"
-/dev/sdd: UUID="CuaqcK-bAgL-GKC1-EiDy-RFyB-oOhd-ZmfRGX" TYPE="LVM2_member"
-/dev/mmcblk0p2: UUID="64a5e86f-5ed3-4c9f-aab3-c4ae24bff95a" TYPE="ext4" LABEL="system"
 /dev/mmcblk0p1: SEC_TYPE="msdos" UUID="3312-932F" TYPE="vfat"
-/dev/sdb1: LABEL="black" UUID="76e7d7d4-f6e9-4867-83a7-03eaa3fc878d" TYPE="ext4"
-/dev/sda1: LABEL="silver" UUID="8095dbdf-9b0a-4dda-9352-d56366af43c8" TYPE="ext4"
+/dev/sdb1: LABEL="My \"Backup\" Disk" UUID="76e7d7d4-f6e9-4867-83a7-03eaa3fc878d" BLOCK_SIZE="4096" TYPE="ext4"
+
+blkid -o export:
+
+DEVNAME=/dev/sdb1
+LABEL=My\ \"Backup\"\ Disk
+UUID=76e7d7d4-f6e9-4867-83a7-03eaa3fc878d
+BLOCK_SIZE=4096
+TYPE=ext4
 */
 
 func (b *BlkidDisks) parse(reader io.Reader) *BlkidDisks {
 
 	scanner := bufio.NewScanner(reader)
 
+	var device string
+	var values map[string]string
+
 	for scanner.Scan() {
 
 		line := scanner.Text()
 
-		r := regexp.MustCompile(`^(/dev/[a-z]+(?:[0-9]+p)?)([\d]+):`) // /dev/sda1: UUID="c6ccdbd5-12da-4b78-98c6-13cd63a733c7" TYPE="ext4" PARTUUID="000bee5a-01"
+		switch {
+		case strings.HasPrefix(line, "DEVNAME="): // blkid -o export
+			b.add(device, values)
+			device, values = unescapeBlkid(strings.TrimPrefix(line, "DEVNAME=")), make(map[string]string)
 
-		var disk *BlkidDisk
-		if matchGroup := r.FindAllStringSubmatch(line, -1); matchGroup != nil {
-
-			d := matchGroup[0]
+		case strings.HasPrefix(line, "/dev/"): // blkid
+			b.add(device, values)
+			device, values = "", nil
+			if i := strings.Index(line, ": "); i > 0 {
+				device, values = line[:i], parseBlkidValues(line[i+2:])
+			}
 
-			diskname := d[1]
+		case strings.TrimSpace(line) == "":
+			b.add(device, values)
+			device, values = "", nil
 
-			if e, ok := b.Disks[diskname]; !ok {
-				disk = &BlkidDisk{Name: diskname, Partitions: make(map[int]*BlkidPartition, 16)}
-				b.Disks[disk.Name] = disk
-			} else {
-				disk = e
+		default:
+			if i := strings.Index(line, "="); i > 0 && device != "" {
+				values[line[:i]] = unescapeBlkid(line[i+1:])
 			}
+		}
+	}
+	b.add(device, values)
+
+	return b
+}
+
+// add - adds a device reported by blkid either as partition of a disk or as whole disk
+func (b *BlkidDisks) add(device string, values map[string]string) {
+
+	if device == "" {
+		return
+	}
+
+	diskName, partitionNumber := device, -1
+	if name, err := ParseDeviceName(device); err == nil && name.IsPartition() && strings.HasPrefix(device, "/dev/") {
+		diskName, partitionNumber = "/dev/"+name.Disk, name.Number
+	}
 
-			partitionNumber, _ := strconv.Atoi(d[2])
-			parts := strings.Split(line, " ")
-
-			partition := NewBlkidPartition(partitionNumber)
-			for i := range parts {
-				e := strings.Split(parts[i], "=")
-				switch e[0] {
-				case "UUID":
-					partition.Uuid = strings.Replace(e[1], `"`, "", -1)
-				case "LABEL":
-					partition.Label = strings.Replace(e[1], `"`, "", -1)
-				case "TYPE":
-					partition.Type = strings.Replace(e[1], `"`, "", -1)
-				case "PARTUUID":
-					partition.Partuuid = strings.Replace(e[1], `"`, "", -1)
-				case "PTTYPE":
-					partition.Pttype = strings.Replace(e[1], `"`, "", -1)
-				}
-				disk.Partitions[partitionNumber] = partition
+	disk, ok := b.Disks[diskName]
+	if !ok {
+		disk = &BlkidDisk{Name: diskName, Partitions: make(map[int]*BlkidPartition, 16)}
+		b.Disks[disk.Name] = disk
+	}
+
+	if partitionNumber < 0 {
+		disk.Uuid, disk.Type, disk.Label, disk.Pttype, disk.Ptuuid = values["UUID"], values["TYPE"], values["LABEL"], values["PTTYPE"], values["PTUUID"]
+		return
+	}
+
+	partition := NewBlkidPartition(partitionNumber)
+	for key, value := range values {
+		switch key {
+		case "UUID":
+			partition.Uuid = value
+		case "LABEL":
+			partition.Label = value
+		case "TYPE":
+			partition.Type = value
+		case "PARTUUID":
+			partition.Partuuid = value
+		case "PTTYPE":
+			partition.Pttype = value
+		case "SEC_TYPE":
+			partition.SecType = value
+		case "BLOCK_SIZE":
+			partition.BlockSize = value
+		case "PARTLABEL":
+			partition.Partlabel = value
+		}
+	}
+	disk.Partitions[partitionNumber] = partition
+}
+
+// parseBlkidValues - parses KEY="value" pairs of blkid. Quotes and backslashes in values are escaped with a backslash
+func parseBlkidValues(line string) map[string]string {
+
+	result := make(map[string]string)
+
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			break
+		}
+		key := line[:i]
+		line = line[i+1:]
+
+		var value bytes.Buffer
+		quoted := strings.HasPrefix(line, `"`)
+		if quoted {
+			line = line[1:]
+		}
+		j := 0
+		for ; j < len(line); j++ {
+			c := line[j]
+			if c == '\\' && j+1 < len(line) {
+				value.WriteByte(c)
+				value.WriteByte(line[j+1])
+				j++
+				continue
+			}
+			if quoted && c == '"' || !quoted && c == ' ' {
+				j++
+				break
 			}
+			value.WriteByte(c)
 		}
+		result[key] = unescapeBlkid(value.String())
+		line = line[j:]
 	}
-	return b
+
+	return result
+}
+
+// unescapeBlkid - removes the backslashes blkid uses to escape unsafe characters and decodes \xHH
+func unescapeBlkid(value string) string {
+	var result bytes.Buffer
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			result.WriteByte(c)
+			continue
+		}
+		if value[i+1] == 'x' && i+3 < len(value) && rawEscape.MatchString(value[i:i+4]) {
+			result.WriteString(unescapeRaw(value[i : i+4]))
+			i += 3
+			continue
+		}
+		result.WriteByte(value[i+1])
+		i++
+	}
+	return result.String()
 }
 
 // NewBlkidFromFile -
@@ -191,9 +320,9 @@ func NewBlkidFromFile(fileName string) (*BlkidDisks, error) {
 }
 
 // NewBlkidToFile -
-func NewBlkidToFile(fileName string) error {
+func NewBlkidToFile(ctx context.Context, fileName string) error {
 
-	b, err := NewBlkidDisks()
+	b, err := NewBlkidDisks(ctx)
 	if err != nil {
 		return err
 	}
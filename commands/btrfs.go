@@ -0,0 +1,192 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// BtrfsTopLevel - id of the top level subvolume of every btrfs filesystem
+const BtrfsTopLevel = 5
+
+// BtrfsSubvolume - subvolume reported by btrfs subvolume list
+type BtrfsSubvolume struct {
+	ID         int
+	Gen        int
+	Parent     int    // id of the subvolume containing the subvolume
+	TopLevel   int    // id of the subvolume the path is relative to
+	Path       string // @home, @snapshots/2024-01-01
+	Uuid       string
+	ParentUuid string // uuid of the snapshot source, empty for subvolumes which are no snapshots
+}
+
+func (s BtrfsSubvolume) String() string {
+	return fmt.Sprintf("Subvolume: %s - ID: %d - Gen: %d - Parent: %d - TopLevel: %d - Uuid: %s - ParentUuid: %s",
+		s.Path, s.ID, s.Gen, s.Parent, s.TopLevel, s.Uuid, s.ParentUuid)
+}
+
+// Btrfs - subvolumes and default subvolume of a btrfs filesystem
+type Btrfs struct {
+	Subvolumes []*BtrfsSubvolume
+	DefaultID  int // subvolume mounted if no subvol option is used
+}
+
+func (b Btrfs) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("DefaultID: %d", b.DefaultID))
+	for _, s := range b.Subvolumes {
+		result.WriteString("\n")
+		result.WriteString(s.String())
+	}
+	return result.String()
+}
+
+/*
+ID 256 gen 2371 parent 5 top level 5 parent_uuid - uuid 3c1b5e2a-7d4f-4a8e-9b60-2f1d8c7e5a34 path @
+ID 257 gen 2370 parent 5 top level 5 parent_uuid - uuid 9e4d2c71-0b8a-4f3e-a5d6-c7b1e9f2a083 path @home
+ID 259 gen 2365 parent 5 top level 5 parent_uuid 3c1b5e2a-7d4f-4a8e-9b60-2f1d8c7e5a34 uuid 5a7f... path @snapshots/root-1
+
+btrfs subvolume get-default reports the default subvolume without parent:
+
+ID 256 gen 2371 top level 5 path @
+ID 5 (FS_TREE)
+*/
+
+// parseBtrfsLine - key value pairs of a line of btrfs subvolume list or get-default. The path is the rest of the line
+func parseBtrfsLine(line string) map[string]string {
+	result := make(map[string]string)
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		key := fields[i]
+		switch key {
+		case "top":
+			if fields[i+1] == "level" && i+2 < len(fields) {
+				result["top level"] = fields[i+2]
+				i += 2
+			}
+			continue
+		case "path":
+			result[key] = strings.Join(fields[i+1:], " ")
+			return result
+		}
+		result[key] = fields[i+1]
+		i++
+	}
+	return result
+}
+
+func (b *Btrfs) parse(reader io.Reader) *Btrfs {
+
+	b.DefaultID = BtrfsTopLevel
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "ID ") {
+			continue
+		}
+		values := parseBtrfsLine(line)
+		id, _ := strconv.Atoi(values["ID"])
+		if _, ok := values["parent"]; !ok { // get-default
+			b.DefaultID = id
+			continue
+		}
+		s := &BtrfsSubvolume{ID: id, Path: values["path"], Uuid: values["uuid"]}
+		s.Gen, _ = strconv.Atoi(values["gen"])
+		s.Parent, _ = strconv.Atoi(values["parent"])
+		s.TopLevel, _ = strconv.Atoi(values["top level"])
+		if parentUuid := values["parent_uuid"]; parentUuid != "-" {
+			s.ParentUuid = parentUuid
+		}
+		b.Subvolumes = append(b.Subvolumes, s)
+	}
+
+	sort.Slice(b.Subvolumes, func(i, j int) bool {
+		return b.Subvolumes[i].ID < b.Subvolumes[j].ID
+	})
+	return b
+}
+
+// Subvolume - subvolume with id, or nil
+func (b Btrfs) Subvolume(id int) *BtrfsSubvolume {
+	for _, s := range b.Subvolumes {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// NewBtrfs - subvolumes of the btrfs filesystem mounted on mountpoint
+func NewBtrfs(ctx context.Context, mountpoint string) (*Btrfs, error) {
+
+	var output bytes.Buffer
+	for _, args := range [][]string{{"subvolume", "list", "-p", "-u", "-q", mountpoint}, {"subvolume", "get-default", mountpoint}} {
+		result, err := NewCommandContext(ctx, TypeSudo, "btrfs", args...).Execute()
+		if err != nil {
+			tools.Logger.Errorf("NewBtrfs failed for %s: %s", mountpoint, err.Error())
+			return nil, err
+		}
+		output.Write(*result)
+	}
+
+	return (&Btrfs{}).parse(&output), nil
+}
+
+// NewBtrfsFromFile - reads btrfs subvolume list -p -u -q output optionally followed by btrfs subvolume get-default output
+func NewBtrfsFromFile(fileName string) (*Btrfs, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return (&Btrfs{}).parse(bytes.NewReader(b)), nil
+}
+
+// BtrfsSnapshot - creates a read only snapshot of a subvolume
+func BtrfsSnapshot(ctx context.Context, subvolume, snapshot string) error {
+	_, err := NewCommandContext(ctx, TypeSudo, "btrfs", "subvolume", "snapshot", "-r", subvolume, snapshot).Execute()
+	return err
+}
+
+// BtrfsDelete - deletes a subvolume or snapshot
+func BtrfsDelete(ctx context.Context, subvolume string) error {
+	_, err := NewCommandContext(ctx, TypeSudo, "btrfs", "subvolume", "delete", subvolume).Execute()
+	return err
+}
+
+// BtrfsSend - sends a read only snapshot into a directory on a btrfs filesystem. Only the differences to parent are
+// sent if parent is not empty. The parent has to exist in the directory already
+func BtrfsSend(ctx context.Context, snapshot, parent, directory string) error {
+
+	args := []string{"send"}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	pipeline := NewPipeline(
+		NewCommandContext(ctx, TypeSudo, "btrfs", append(args, snapshot)...),
+		NewCommandContext(ctx, TypeSudo, "btrfs", "receive", directory))
+	pipeline.Stdout = ioutil.Discard
+
+	stream, err := pipeline.Start()
+	if err != nil {
+		return err
+	}
+	return stream.Wait()
+}
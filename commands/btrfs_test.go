@@ -1,4 +1,4 @@
-package tools
+package commands
 
 //######################################################################################################################
 //
@@ -8,9 +8,10 @@ package tools
 //
 //#######################################################################################################################
 
-import "strings"
+import (
+	"testing"
+)
 
-// IsSpecialPartition -
-func IsSpecialPartition(deviceName string) bool {
-	return strings.HasPrefix(deviceName, "/dev/mmcblk") || strings.HasPrefix(deviceName, "/dev/loop")
+func TestBtrfs(t *testing.T) {
+	VerifyData(t, BtrfsSubvolumes, "btrfs")
 }
@@ -9,21 +9,34 @@ package commands
 //#######################################################################################################################
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
 	"strings"
+	"time"
 
 	"github.com/framps/raspiBackupNext/tools"
 )
 
 // Cmd -
 type Cmd struct {
-	*exec.Cmd
+	Type      CommandType   // normal, sudo or bash
+	Command   string        // command as passed to NewCommand, e.g. parted
+	Arguments []string      // arguments as passed to NewCommand
+	Args      []string      // effective argv, e.g. [sudo -n parted -m /dev/sda unit B print]
+	Timeout   time.Duration // 0: no timeout
+	Stderr    io.Writer     // Start only: receives stderr while the command runs, e.g. progress of dd
+	Env       []string      // ControlledEnvironment or the inherited environment
+
+	ctx      context.Context
+	mutating bool // not executed in dry-run mode
+	inherit  bool // Env is the inherited environment
 }
 
 // String -
 func (c Cmd) String() string {
-	return fmt.Sprintf("%s %s", c.Path, c.Args)
+	return strings.Join(c.Args, " ")
 }
 
 // CommandType -
@@ -45,33 +58,136 @@ func (t CommandType) String() string {
 	return TypeStrings[t]
 }
 
-// NewCommand -
+// DefaultTimeouts - timeouts used for commands if no explicit timeout is set. Commands not listed have no timeout
+var DefaultTimeouts = map[string]time.Duration{
+	"blkid":   30 * time.Second,
+	"findmnt": 10 * time.Second,
+	"lsblk":   10 * time.Second,
+	"lvs":     30 * time.Second,
+	"parted":  60 * time.Second,
+	"pvs":     30 * time.Second,
+	"vgs":     30 * time.Second,
+}
+
+// TimeoutError - returned if a command didn't terminate within its timeout
+type TimeoutError struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Command '%s' timed out after %s", e.Command, e.Timeout)
+}
+
+// Unwrap -
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// IsTimeout -
+func IsTimeout(err error) bool {
+	var timeoutError *TimeoutError
+	return errors.As(err, &timeoutError)
+}
+
+// NewCommand - creates a command which cannot be cancelled other than by its default timeout
 func NewCommand(commandType CommandType, command string, args ...string) *Cmd {
+	return NewCommandContext(context.Background(), commandType, command, args...)
+}
+
+// NewCommandContext - creates a command which is killed together with all its child processes if ctx is done
+func NewCommandContext(ctx context.Context, commandType CommandType, command string, args ...string) *Cmd {
+
+	result := &Cmd{Type: commandType, Command: command, Arguments: args, Timeout: DefaultTimeouts[command],
+		Env: append([]string{}, ControlledEnvironment...), ctx: ctx, mutating: isMutating(commandType, command, args)}
+	result.build()
+
+	return result
+}
 
-	var result *Cmd
-	switch commandType {
+// build - computes the effective argv
+func (c *Cmd) build() {
+	switch c.Type {
 	case TypeNormal:
-		result = &Cmd{exec.Command(command, args...)}
+		c.Args = append([]string{c.Command}, c.Arguments...)
 	case TypeSudo:
-		result = &Cmd{exec.Command("sudo", append([]string{command}, args...)...)}
+		env := explicitEnvironment()
+		if !c.inherit {
+			env = c.Env
+		}
+		c.Args = escalate(append([]string{c.Command}, c.Arguments...), env)
 	case TypeBash:
-		result = &Cmd{exec.Command("sh", append([]string{"-c"}, command, strings.Join(args, " "))...)}
+		c.Args = []string{"sh", "-c", c.Command, strings.Join(c.Arguments, " ")}
 	default:
-		tools.HandleError(fmt.Errorf("Invalid command type %s", commandType))
+		tools.HandleError(fmt.Errorf("Invalid command type %s", c.Type))
 	}
+}
 
-	return result
+// WithTimeout - overrides the default timeout of the command, 0 disables the timeout
+func (c *Cmd) WithTimeout(timeout time.Duration) *Cmd {
+	c.Timeout = timeout
+	return c
+}
+
+// Context - context of the command limited by its timeout. The returned cancel function has to be called
+// when the command terminated
+func (c *Cmd) Context() (context.Context, context.CancelFunc) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if c.Timeout > 0 {
+		return context.WithTimeout(ctx, c.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// contextError - maps the error of a terminated command context to a TimeoutError or a cancellation error
+func (c *Cmd) contextError(ctx context.Context) error {
+	switch {
+	case ctx.Err() == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &TimeoutError{Command: c.String(), Timeout: c.Timeout}
+	default:
+		return fmt.Errorf("Command '%s' cancelled: %w", c, ctx.Err())
+	}
 }
 
-// Execute -
+// Execute - executes the command and returns its stdout. Stderr is logged only and not returned so it cannot
+// corrupt the output consumed by the parsers. Use Start for commands with large output. Errors are returned as *CommandError
 func (c *Cmd) Execute() (*[]byte, error) {
-	tools.Logger.Debug("Executing command ", c.Path, c.Args)
-	stdoutStderr, err := c.CombinedOutput()
+	stdout, _, err := c.Output()
+	return &stdout, err
+}
+
+// Output - executes the command and returns its stdout and stderr separately, e.g. for tools which report their
+// version on stderr. Errors are returned as *CommandError
+func (c *Cmd) Output() ([]byte, []byte, error) {
+	if skipDryRun(c.String(), c.mutating) {
+		return []byte{}, []byte{}, nil
+	}
+
+	tools.Logger.Debug("Executing command ", c.Args)
+	start := time.Now()
+	invocation, err := CurrentRunner().Run(c)
+	var (
+		stdout   []byte
+		stderr   string
+		exitCode int
+	)
+	if invocation != nil {
+		stdout, stderr, exitCode = []byte(invocation.Stdout), invocation.Stderr, invocation.ExitCode
+		if len(stderr) > 0 {
+			tools.Logger.Debugf("Command stderr:\n%s", stderr)
+		}
+	}
 	if err != nil {
+		err = newCommandError(c, exitCode, stderr, time.Since(start), err)
 		tools.Logger.Errorf("Command error: %s", err)
-		return &stdoutStderr, err
+		return stdout, []byte(stderr), err
 	}
-	tools.Logger.Debugf("Command result:\n%s", stdoutStderr)
-	return &stdoutStderr, nil
+	tools.Logger.Debugf("Command result:\n%s", stdout)
+	return stdout, []byte(stderr), nil
 
 }
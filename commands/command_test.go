@@ -9,7 +9,10 @@ package commands
 //#######################################################################################################################
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -63,3 +66,30 @@ func TestCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestCommandTimeout(t *testing.T) {
+
+	start := time.Now()
+	// the background sleep holds stdout open and is only killed together with its process group
+	_, err := NewCommand(TypeBash, "sleep 10 & sleep 10; wait").WithTimeout(100 * time.Millisecond).Execute()
+	assert.Error(t, err)
+	assert.True(t, IsTimeout(err))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < waitDelay)
+}
+
+func TestCommandCancel(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := NewCommandContext(ctx, TypeBash, "sleep 10 & sleep 10; wait").Execute()
+	assert.Error(t, err)
+	assert.False(t, IsTimeout(err))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, time.Since(start) < waitDelay)
+
+	_, err = NewCommandContext(ctx, TypeNormal, "echo", "Hello world").Execute()
+	assert.True(t, errors.Is(err, context.Canceled))
+}
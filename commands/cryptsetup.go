@@ -0,0 +1,142 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// CryptStatus - status of an open dm-crypt mapping reported by cryptsetup status
+type CryptStatus struct {
+	Name       string // cryptroot
+	Type       string // LUKS1, LUKS2 or PLAIN
+	Cipher     string // aes-xts-plain64
+	KeySize    int    // bits
+	Device     string // container, e.g. /dev/mmcblk0p2
+	SectorSize int
+	Offset     int64 // 512 byte sectors of the LUKS header
+	Size       int64 // 512 byte sectors of the mapping
+	Mode       string
+}
+
+func (s CryptStatus) String() string {
+	return fmt.Sprintf("Name: %s - Type: %s - Cipher: %s - KeySize: %d - Device: %s - SectorSize: %d - Offset: %d - Size: %d - Mode: %s",
+		s.Name, s.Type, s.Cipher, s.KeySize, s.Device, s.SectorSize, s.Offset, s.Size, s.Mode)
+}
+
+/*
+/dev/mapper/cryptroot is active and is in use.
+  type:    LUKS2
+  cipher:  aes-xts-plain64
+  keysize: 512 bits
+  key location: keyring
+  device:  /dev/mmcblk0p2
+  sector size:  512
+  offset:  32768 sectors
+  size:    30965760 sectors
+  mode:    read/write
+*/
+
+func (s *CryptStatus) parse(reader io.Reader) *CryptStatus {
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "/dev/mapper/") {
+			s.Name = strings.TrimPrefix(strings.Fields(line)[0], "/dev/mapper/")
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		number := strings.Fields(value + " 0")[0] // 512 bits, 32768 sectors
+		switch key {
+		case "type":
+			s.Type = value
+		case "cipher":
+			s.Cipher = value
+		case "keysize":
+			s.KeySize, _ = strconv.Atoi(number)
+		case "device":
+			s.Device = value
+		case "sector size":
+			s.SectorSize, _ = strconv.Atoi(number)
+		case "offset":
+			s.Offset, _ = strconv.ParseInt(number, 10, 64)
+		case "size":
+			s.Size, _ = strconv.ParseInt(number, 10, 64)
+		case "mode":
+			s.Mode = value
+		}
+	}
+	return s
+}
+
+// NewCryptStatus - status of an open dm-crypt mapping, e.g. cryptroot
+func NewCryptStatus(ctx context.Context, name string) (*CryptStatus, error) {
+
+	command := NewCommandContext(ctx, TypeSudo, "cryptsetup", "status", name)
+	result, err := command.Execute()
+	if err != nil {
+		tools.Logger.Errorf("NewCryptStatus failed for %s: %s", name, err.Error())
+		return nil, err
+	}
+
+	return (&CryptStatus{Name: name}).parse(strings.NewReader(string(*result))), nil
+}
+
+// NewCryptStatusFromFile -
+func NewCryptStatusFromFile(fileName string) (*CryptStatus, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return (&CryptStatus{}).parse(strings.NewReader(string(b))), nil
+}
+
+// IsLuks - device contains a LUKS header. False if cryptsetup is not installed
+func IsLuks(ctx context.Context, device string) (bool, error) {
+
+	_, err := NewCommandContext(ctx, TypeSudo, "cryptsetup", "isLuks", device).Execute()
+	var commandError *CommandError
+	switch {
+	case err == nil:
+		return true, nil
+	case IsNotFound(err):
+		tools.Logger.Debugf("IsLuks: cryptsetup not installed")
+		return false, nil
+	case errors.As(err, &commandError) && commandError.ExitCode == 1:
+		return false, nil
+	}
+	return false, err
+}
+
+// LuksHeaderBackup - saves the LUKS header and the key slots of a container into a file
+func LuksHeaderBackup(ctx context.Context, device, fileName string) error {
+	_, err := NewCommandContext(ctx, TypeSudo, "cryptsetup", "luksHeaderBackup", device, "--header-backup-file", fileName).Execute()
+	return err
+}
+
+// LuksHeaderRestore - recreates the LUKS header and the key slots of a container from a header backup
+func LuksHeaderRestore(ctx context.Context, device, fileName string) error {
+	_, err := NewCommandContext(ctx, TypeSudo, "cryptsetup", "luksHeaderRestore", device, "--header-backup-file", fileName, "--batch-mode").Execute()
+	return err
+}
@@ -0,0 +1,17 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"testing"
+)
+
+func TestCryptsetup(t *testing.T) {
+	VerifyData(t, Cryptsetup, "cryptsetup")
+}
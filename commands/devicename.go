@@ -0,0 +1,98 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DeviceName - kernel name of a block device split into disk and partition number. The kernel appends the partition
+// number to the disk name and inserts a p if the disk name ends with a digit
+type DeviceName struct {
+	Disk   string // sda, mmcblk0, nvme0n1, mmcblk0boot0
+	Infix  string // p for mmcblk0p1 or nvme0n1p1
+	Number int    // partition number, 0 for a whole disk
+	Area   string // boot0, boot1 or rpmb for the hardware partitions of an eMMC
+}
+
+func (n DeviceName) String() string {
+	return fmt.Sprintf("Disk: %s Infix: %s Number: %d Area: %s", n.Disk, n.Infix, n.Number, n.Area)
+}
+
+// Name - kernel name of the device, e.g. nvme0n1p2
+func (n DeviceName) Name() string {
+	if n.Number == 0 {
+		return n.Disk
+	}
+	return fmt.Sprintf("%s%s%d", n.Disk, n.Infix, n.Number)
+}
+
+// IsPartition -
+func (n DeviceName) IsPartition() bool {
+	return n.Number > 0
+}
+
+// IsHardwarePartition - boot or rpmb area of an eMMC. They are reported as disks but never contain user data
+func (n DeviceName) IsHardwarePartition() bool {
+	return n.Area != ""
+}
+
+var (
+	// disks named with letters, partitions are appended without infix: sda1, vdb2, xvda1, hda1
+	letterDiskPattern = regexp.MustCompile(`^((?:sd|hd|vd|xvd)[a-z]+)(\d*)$`)
+	// disks named with a trailing number, partitions are appended with infix p: mmcblk0p1, nvme0n1p1, md127p1, nbd0p1
+	numberDiskPattern = regexp.MustCompile(`^((?:mmcblk|loop|md|nbd|zram|ram|sr|dm-)\d+|nvme\d+n\d+)(?:(p)(\d+))?$`)
+	// hardware partitions of an eMMC: mmcblk0boot0, mmcblk0boot1, mmcblk0rpmb
+	mmcAreaPattern = regexp.MustCompile(`^mmcblk\d+(boot\d+|rpmb)$`)
+	// any other disk with a trailing number and a partition
+	otherPartitionPattern = regexp.MustCompile(`^([a-z][a-z0-9_-]*\d)(p)(\d+)$`)
+)
+
+// ParseDeviceName - parses the kernel name of a block device, e.g. nvme0n1p2 or /dev/mmcblk0p1
+func ParseDeviceName(name string) (*DeviceName, error) {
+
+	kernelName := strings.TrimPrefix(name, "/dev/")
+
+	if m := mmcAreaPattern.FindStringSubmatch(kernelName); m != nil {
+		return &DeviceName{Disk: kernelName, Area: m[1]}, nil
+	}
+
+	if m := letterDiskPattern.FindStringSubmatch(kernelName); m != nil {
+		n := &DeviceName{Disk: m[1]}
+		if m[2] != "" {
+			n.Number, _ = strconv.Atoi(m[2])
+		}
+		return n, nil
+	}
+
+	for _, pattern := range []*regexp.Regexp{numberDiskPattern, otherPartitionPattern} {
+		if m := pattern.FindStringSubmatch(kernelName); m != nil {
+			n := &DeviceName{Disk: m[1]}
+			if m[3] != "" {
+				n.Infix = m[2]
+				n.Number, _ = strconv.Atoi(m[3])
+			}
+			return n, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown device name %s", name)
+}
+
+// PartitionName - name of a partition of a disk, e.g. /dev/sda1 for /dev/sda or /dev/nvme0n1p1 for /dev/nvme0n1
+func PartitionName(disk string, number int) string {
+	if disk != "" && unicode.IsDigit(rune(disk[len(disk)-1])) {
+		return fmt.Sprintf("%sp%d", disk, number)
+	}
+	return fmt.Sprintf("%s%d", disk, number)
+}
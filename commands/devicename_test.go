@@ -0,0 +1,69 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeviceName(t *testing.T) {
+
+	names := []struct {
+		Name     string
+		Expected DeviceName
+		Err      bool
+	}{
+		{"sda", DeviceName{Disk: "sda"}, false},
+		{"/dev/sda1", DeviceName{Disk: "sda", Number: 1}, false},
+		{"sdaa12", DeviceName{Disk: "sdaa", Number: 12}, false},
+		{"vdb2", DeviceName{Disk: "vdb", Number: 2}, false},
+		{"xvda1", DeviceName{Disk: "xvda", Number: 1}, false},
+		{"mmcblk0", DeviceName{Disk: "mmcblk0"}, false},
+		{"/dev/mmcblk0p2", DeviceName{Disk: "mmcblk0", Infix: "p", Number: 2}, false},
+		{"mmcblk0boot0", DeviceName{Disk: "mmcblk0boot0", Area: "boot0"}, false},
+		{"mmcblk1rpmb", DeviceName{Disk: "mmcblk1rpmb", Area: "rpmb"}, false},
+		{"nvme0n1", DeviceName{Disk: "nvme0n1"}, false},
+		{"/dev/nvme0n1p2", DeviceName{Disk: "nvme0n1", Infix: "p", Number: 2}, false},
+		{"nvme1n12p10", DeviceName{Disk: "nvme1n12", Infix: "p", Number: 10}, false},
+		{"md127", DeviceName{Disk: "md127"}, false},
+		{"md127p1", DeviceName{Disk: "md127", Infix: "p", Number: 1}, false},
+		{"nbd0p1", DeviceName{Disk: "nbd0", Infix: "p", Number: 1}, false},
+		{"loop7", DeviceName{Disk: "loop7"}, false},
+		{"loop0p2", DeviceName{Disk: "loop0", Infix: "p", Number: 2}, false},
+		{"zram0", DeviceName{Disk: "zram0"}, false},
+		{"dm-0", DeviceName{Disk: "dm-0"}, false},
+		{"ubiblock0p3", DeviceName{Disk: "ubiblock0", Infix: "p", Number: 3}, false},
+		// failures
+		{"578", DeviceName{}, true},
+		{"mapper/cryptroot", DeviceName{}, true},
+		{"nvme0", DeviceName{}, true},
+		{"", DeviceName{}, true},
+	}
+
+	for _, n := range names {
+		t.Logf("Parsing %s\n", n.Name)
+		name, err := ParseDeviceName(n.Name)
+		if n.Err {
+			assert.Error(t, err)
+			continue
+		}
+		if assert.NoError(t, err) {
+			assert.Equal(t, n.Expected, *name)
+			assert.Equal(t, n.Expected.Number > 0, name.IsPartition())
+		}
+	}
+
+	assert.Equal(t, "nvme0n1p2", DeviceName{Disk: "nvme0n1", Infix: "p", Number: 2}.Name())
+	assert.Equal(t, "/dev/sda1", PartitionName("/dev/sda", 1))
+	assert.Equal(t, "/dev/mmcblk0p2", PartitionName("/dev/mmcblk0", 2))
+	assert.Equal(t, "/dev/nvme0n1p3", PartitionName("/dev/nvme0n1", 3))
+	assert.Equal(t, "/dev/loop0p1", PartitionName("/dev/loop0", 1))
+}
@@ -0,0 +1,79 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DiscoveryError - failure of a tool while discovering the system. Disk is set if only this disk is affected
+type DiscoveryError struct {
+	Tool string // lsblk, parted, ...
+	Disk string // /dev/sda or empty
+	Err  error
+}
+
+func (e *DiscoveryError) Error() string {
+	if e.Disk != "" {
+		return fmt.Sprintf("%s failed for %s: %s", e.Tool, e.Disk, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Tool, e.Err)
+}
+
+// Unwrap -
+func (e *DiscoveryError) Unwrap() error {
+	return e.Err
+}
+
+// DiscoveryErrors - all failures of a discovery in the order they were detected
+type DiscoveryErrors []*DiscoveryError
+
+func (e DiscoveryErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, d := range e {
+		messages = append(messages, d.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap - allows errors.Is and errors.As to match any of the failures
+func (e DiscoveryErrors) Unwrap() []error {
+	result := make([]error, 0, len(e))
+	for _, d := range e {
+		result = append(result, d)
+	}
+	return result
+}
+
+// Add - adds the failure of tool, nil errors are ignored
+func (e *DiscoveryErrors) Add(tool, disk string, err error) {
+	if err == nil {
+		return
+	}
+	*e = append(*e, &DiscoveryError{Tool: tool, Disk: disk, Err: err})
+}
+
+// Err - nil if there were no failures. Use it to return DiscoveryErrors as error
+func (e DiscoveryErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// AsDiscoveryErrors - failures of a discovery error, nil for other errors
+func AsDiscoveryErrors(err error) DiscoveryErrors {
+	var result DiscoveryErrors
+	if errors.As(err, &result) {
+		return result
+	}
+	return nil
+}
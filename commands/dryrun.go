@@ -0,0 +1,143 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// readOnlyCommands - commands which never modify the system. All commands not listed here or in readOnlyArguments
+// are considered to be mutating and are not executed in dry-run mode
+var readOnlyCommands = map[string]bool{
+	"blkid": true, "cat": true, "df": true, "du": true, "dumpe2fs": true, "echo": true, "file": true, "findfs": true,
+	"findmnt": true, "grep": true, "head": true, "id": true, "ls": true, "lsblk": true, "lvs": true, "printf": true,
+	"pvs": true, "pwd": true, "stat": true, "tail": true, "uname": true, "vgs": true, "wc": true, "which": true,
+}
+
+// readOnlyArguments - commands which are read only if called with one of the arguments
+var readOnlyArguments = map[string][]string{
+	"btrfs":      {"list", "show", "df", "usage", "get-default"},
+	"cryptsetup": {"status", "luksDump", "isLuks"},
+	"e2fsck":     {"-n"},
+	"fsck":       {"-n"},
+	"mdadm":      {"--detail", "--examine", "--query"},
+	"parted":     {"print"},
+	"tar":        {"-t", "--list"},
+}
+
+// mutatingArguments - arguments which make a command of readOnlyArguments mutating even if a read only argument is used,
+// e.g. parted /dev/sda mklabel msdos print
+var mutatingArguments = map[string][]string{
+	"btrfs":  {"snapshot", "delete", "create", "send", "receive", "set-default"},
+	"parted": {"mklabel", "mktable", "mkpart", "rm", "resizepart", "name", "set", "toggle", "rescue", "disk_set", "disk_toggle", "type"},
+}
+
+// versionArguments - calls which only query the version or usage of a command
+var versionArguments = map[string]bool{"--version": true, "-V": true, "-v": true, "version": true, "--help": true}
+
+func containsAny(args []string, values []string) bool {
+	for _, a := range args {
+		for _, v := range values {
+			if a == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMutating - true if the command writes, mounts, formats or partitions. Shell commands cannot be analyzed and are
+// always considered mutating
+func isMutating(commandType CommandType, command string, args []string) bool {
+
+	if commandType == TypeBash {
+		return true
+	}
+
+	name := filepath.Base(command)
+	switch {
+	case readOnlyCommands[name]:
+		return false
+	case len(args) == 1 && versionArguments[args[0]]:
+		return false
+	}
+
+	if readOnly, ok := readOnlyArguments[name]; ok {
+		return !containsAny(args, readOnly) || containsAny(args, mutatingArguments[name])
+	}
+	return true
+}
+
+var (
+	dryRunMutex  sync.RWMutex
+	dryRun       bool
+	dryRunOutput io.Writer = os.Stdout
+)
+
+// SetDryRun - enables or disables dry-run mode. Mutating commands are written to output with their effective argv
+// instead of being executed, read only commands are still executed
+func SetDryRun(enabled bool, output io.Writer) {
+	dryRunMutex.Lock()
+	defer dryRunMutex.Unlock()
+	dryRun = enabled
+	if output != nil {
+		dryRunOutput = output
+	}
+}
+
+// DryRun - true if dry-run mode is enabled. Code which modifies the system without commands, e.g. by writing files,
+// has to check this too
+func DryRun() bool {
+	dryRunMutex.RLock()
+	defer dryRunMutex.RUnlock()
+	return dryRun
+}
+
+// skipDryRun - true if the command must not be executed. The command is reported in this case
+func skipDryRun(description string, mutating bool) bool {
+	dryRunMutex.RLock()
+	defer dryRunMutex.RUnlock()
+	if !dryRun || !mutating {
+		return false
+	}
+	fmt.Fprintf(dryRunOutput, "DRY-RUN: %s\n", description)
+	return true
+}
+
+// MarkMutating - forces the command to be considered mutating
+func (c *Cmd) MarkMutating() *Cmd {
+	c.mutating = true
+	return c
+}
+
+// MarkReadOnly - forces the command to be considered read only, e.g. a shell command which only reads
+func (c *Cmd) MarkReadOnly() *Cmd {
+	c.mutating = false
+	return c
+}
+
+// IsMutating -
+func (c *Cmd) IsMutating() bool {
+	return c.mutating
+}
+
+// dryRunStream - stream of a pipeline which was not executed
+func dryRunStream(p *Pipeline) *Stream {
+	stream := &Stream{pipeline: p.String()}
+	if p.Stdout == nil {
+		stream.Stdout = ioutil.NopCloser(strings.NewReader(""))
+	}
+	return stream
+}
@@ -0,0 +1,86 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutating(t *testing.T) {
+
+	cmds := []struct {
+		Type     CommandType
+		Command  string
+		Args     []string
+		Mutating bool
+	}{
+		{TypeSudo, "lsblk", []string{"-r", "-n", "-b"}, false},
+		{TypeSudo, "blkid", []string{}, false},
+		{TypeSudo, "parted", []string{"-m", "/dev/sda", "unit", "B", "print"}, false},
+		{TypeSudo, "parted", []string{"-s", "/dev/sda", "mklabel", "msdos", "print"}, true},
+		{TypeSudo, "parted", []string{"-s", "/dev/sda", "mkpart", "primary", "1MiB", "100%"}, true},
+		{TypeSudo, "e2fsck", []string{"-n", "/dev/sda1"}, false},
+		{TypeSudo, "e2fsck", []string{"-p", "/dev/sda1"}, true},
+		{TypeNormal, "rsync", []string{"--version"}, false},
+		{TypeSudo, "rsync", []string{"-aHAX", "/", "/backup"}, true},
+		{TypeSudo, "dd", []string{"if=/dev/mmcblk0", "bs=1M"}, true},
+		{TypeSudo, "mkfs.ext4", []string{"/dev/sda2"}, true},
+		{TypeSudo, "mount", []string{"/dev/sda1", "/mnt"}, true},
+		{TypeNormal, "/usr/bin/lsblk", []string{"-J"}, false},
+		{TypeBash, "ls", []string{}, true},
+	}
+
+	for _, c := range cmds {
+		assert.Equalf(t, c.Mutating, NewCommand(c.Type, c.Command, c.Args...).IsMutating(), "%s %v", c.Command, c.Args)
+	}
+	assert.False(t, NewCommand(TypeBash, "ls").MarkReadOnly().IsMutating())
+	assert.True(t, NewCommand(TypeNormal, "echo").MarkMutating().IsMutating())
+}
+
+func TestDryRun(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	dir, err := ioutil.TempDir("", "raspiBackupDryRun")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "created")
+
+	var output bytes.Buffer
+	SetDryRun(true, &output)
+	defer SetDryRun(false, os.Stdout)
+
+	result, err := NewCommand(TypeNormal, "touch", fileName).Execute()
+	assert.NoError(t, err)
+	assert.Empty(t, *result)
+	_, err = os.Stat(fileName)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, "DRY-RUN: touch "+fileName+"\n", output.String())
+
+	result, err = NewCommand(TypeNormal, "echo", "Hello world").Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello world\n", string(*result))
+
+	output.Reset()
+	stream, err := NewPipeline(NewCommand(TypeNormal, "echo", "x"), NewCommand(TypeNormal, "tee", fileName)).Start()
+	assert.NoError(t, err)
+	stdout, _ := ioutil.ReadAll(stream.Stdout)
+	assert.NoError(t, stream.Wait())
+	assert.Empty(t, stdout)
+	_, err = os.Stat(fileName)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, "DRY-RUN: echo x | tee "+fileName+"\n", output.String())
+}
@@ -0,0 +1,88 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FixedPath - PATH of commands with a controlled environment
+const FixedPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// ControlledEnvironment - environment of all commands whose output is parsed. It guarantees unlocalized output and
+// doesn't pass ENV or BASH_ENV so no user aliases or functions are defined for TypeBash commands
+var ControlledEnvironment = []string{"LC_ALL=C", "LANG=C", "PATH=" + FixedPath}
+
+var (
+	environmentMutex     sync.RWMutex
+	inheritedEnvironment []string // nil: environment of raspiBackup
+)
+
+// SetInheritedEnvironment - sets the environment of commands which inherit the environment, e.g. backup tools.
+// nil restores the environment of raspiBackup
+func SetInheritedEnvironment(env []string) {
+	environmentMutex.Lock()
+	defer environmentMutex.Unlock()
+	inheritedEnvironment = env
+}
+
+// InheritedEnvironment - environment of commands which inherit the environment
+func InheritedEnvironment() []string {
+	environmentMutex.RLock()
+	defer environmentMutex.RUnlock()
+	if inheritedEnvironment == nil {
+		return os.Environ()
+	}
+	return append([]string{}, inheritedEnvironment...)
+}
+
+// explicitEnvironment - environment whose variables sudo has to keep because it resets the environment, nil if
+// raspiBackup's environment is inherited
+func explicitEnvironment() []string {
+	environmentMutex.RLock()
+	defer environmentMutex.RUnlock()
+	return inheritedEnvironment
+}
+
+// InheritEnvironment - executes the command with the inherited environment instead of the controlled one.
+// Used for tools whose output isn't parsed, e.g. backup tools
+func (c *Cmd) InheritEnvironment() *Cmd {
+	c.inherit = true
+	c.Env = InheritedEnvironment()
+	c.build()
+	return c
+}
+
+// pathOf - PATH of an environment
+func pathOf(env []string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], "PATH=") {
+			return strings.TrimPrefix(env[i], "PATH=")
+		}
+	}
+	return ""
+}
+
+// lookPath - locates the executable in the PATH of the command environment and not in the PATH of raspiBackup
+func lookPath(name string, env []string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+	for _, dir := range filepath.SplitList(pathOf(env)) {
+		candidate := filepath.Join(dir, name)
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
@@ -0,0 +1,93 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// localeRunner - fake runner which returns german output if the command isn't executed with LC_ALL=C
+type localeRunner struct{}
+
+func (localeRunner) Run(c *Cmd) (*Invocation, error) {
+
+	english := false
+	for _, e := range append(append([]string{}, c.Env...), c.Args...) {
+		english = english || e == "LC_ALL=C"
+	}
+
+	invocation := newInvocation(c)
+	switch {
+	case c.Command == "parted" && english:
+		invocation.Stdout = "BYT;\n/dev/mmcblk0:15931539456B:sd/mmc:512:512:msdos:SD SL16G:;\n" +
+			"1:4194304B:62914559B:58720256B:fat16::lba;\n2:62914560B:15931539455B:15868624896B:ext4::;\n"
+	case c.Command == "parted":
+		invocation.Stdout = "BYT;\n/dev/mmcblk0:15,9GB:sd/mmc:512:512:msdos:SD SL16G:;\n" +
+			"1:4,19MB:62,9MB:58,7MB:fat16::lba;\n2:62,9MB:15,9GB:15,9GB:ext4::;\n"
+	}
+	return invocation, nil
+}
+
+func TestLocaleIndependence(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	previous := SetRunner(localeRunner{})
+	defer SetRunner(previous)
+
+	for _, e := range []*Escalation{EscalationNone, EscalationSudo, EscalationDoas} {
+		previousEscalation := SetEscalation(e)
+		disk, err := NewPartedDisk(context.Background(), "/dev/mmcblk0")
+		SetEscalation(previousEscalation)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "15931539456B", disk.Size)
+		assert.Equal(t, int64(4194304), disk.Partitions[1].Start)
+		assert.Equal(t, int64(15868624896), disk.Partitions[2].Size)
+	}
+}
+
+func TestControlledEnvironment(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	dir, err := ioutil.TempDir("", "raspiBackupEnv")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// an alias file sourced by interactive shells must not be used
+	aliases := filepath.Join(dir, "aliases")
+	assert.NoError(t, ioutil.WriteFile(aliases, []byte("alias ls='echo aliased'\n"), 0644))
+	os.Setenv("ENV", aliases)
+	os.Setenv("LC_ALL", "de_DE.UTF-8")
+	defer os.Unsetenv("ENV")
+	defer os.Unsetenv("LC_ALL")
+
+	result, err := NewCommand(TypeBash, "echo $LC_ALL:$ENV:$PATH").Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "C::"+FixedPath, strings.TrimSpace(string(*result)))
+
+	result, err = NewCommand(TypeBash, "echo $LC_ALL").InheritEnvironment().Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "de_DE.UTF-8", strings.TrimSpace(string(*result)))
+
+	SetInheritedEnvironment([]string{"RSYNC_RSH=ssh", "PATH=" + os.Getenv("PATH")})
+	defer SetInheritedEnvironment(nil)
+	result, err = NewCommand(TypeBash, "echo $RSYNC_RSH:$LC_ALL").InheritEnvironment().Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "ssh:", strings.TrimSpace(string(*result)))
+}
@@ -0,0 +1,119 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxStderrTail - number of trailing stderr bytes kept in a CommandError
+const maxStderrTail = 4 * 1024
+
+// CommandError - error of a failed command
+type CommandError struct {
+	Args     []string // effective argv
+	Type     CommandType
+	ExitCode int    // -1 if the command could not be started or was killed
+	Stderr   string // trailing stderr output
+	Duration time.Duration
+	Err      error // underlying error, e.g. *exec.ExitError or *TimeoutError
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("Command '%s' failed: %s", strings.Join(e.Args, " "), e.Err)
+	if line := e.lastStderrLine(); line != "" {
+		msg += fmt.Sprintf(" (%s)", line)
+	}
+	return msg
+}
+
+// Unwrap -
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CommandError) lastStderrLine() string {
+	lines := strings.Split(strings.TrimSpace(e.Stderr), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func newCommandError(c *Cmd, exitCode int, stderr string, duration time.Duration, err error) *CommandError {
+	var commandError *CommandError
+	if errors.As(err, &commandError) {
+		return commandError
+	}
+	if len(stderr) > maxStderrTail {
+		stderr = stderr[len(stderr)-maxStderrTail:]
+	}
+	if exitCode == 0 {
+		exitCode = -1
+		var exitError *exec.ExitError
+		var exitStatusError *ExitStatusError
+		if errors.As(err, &exitError) {
+			exitCode = exitError.ExitCode()
+		} else if errors.As(err, &exitStatusError) {
+			exitCode = exitStatusError.ExitCode
+		}
+	}
+	return &CommandError{Args: c.Args, Type: c.Type, ExitCode: exitCode, Stderr: stderr, Duration: duration, Err: err}
+}
+
+var (
+	notFoundPattern         = regexp.MustCompile(`(?i)command not found|executable file not found`)
+	permissionDeniedPattern = regexp.MustCompile(`(?i)permission denied|operation not permitted|a password is required|must be (run as )?root|must be superuser`)
+	noMediumPattern         = regexp.MustCompile(`(?i)no medium found`)
+	noDiskLabelPattern      = regexp.MustCompile(`(?i)unrecogni[sz]ed disk label`)
+	noSuchFilePattern       = regexp.MustCompile(`(?i)no such file or directory`)
+)
+
+// classify - true if err is a CommandError matching one of the exit codes, errnos or stderr pattern
+func classify(err error, exitCodes []int, errnos []syscall.Errno, pattern *regexp.Regexp) bool {
+	var commandError *CommandError
+	if !errors.As(err, &commandError) {
+		return false
+	}
+	for _, code := range exitCodes {
+		if commandError.ExitCode == code {
+			return true
+		}
+	}
+	for _, errno := range errnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return pattern.MatchString(commandError.Stderr) || pattern.MatchString(commandError.Err.Error())
+}
+
+// IsNotFound - the command is not installed
+func IsNotFound(err error) bool {
+	return errors.Is(err, exec.ErrNotFound) || classify(err, []int{127}, []syscall.Errno{syscall.ENOENT}, notFoundPattern)
+}
+
+// IsPermissionDenied - the command or the device could not be accessed with the current privileges
+func IsPermissionDenied(err error) bool {
+	return errors.Is(err, os.ErrPermission) || classify(err, []int{126}, []syscall.Errno{syscall.EACCES, syscall.EPERM}, permissionDeniedPattern)
+}
+
+// IsNoMedium - the device has no medium, e.g. a card reader without SD card
+func IsNoMedium(err error) bool {
+	return classify(err, nil, nil, noMediumPattern)
+}
+
+// IsNoDiskLabel - the disk has no partition table, e.g. a new disk
+func IsNoDiskLabel(err error) bool {
+	return classify(err, nil, nil, noDiskLabelPattern)
+}
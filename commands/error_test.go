@@ -0,0 +1,74 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandError(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	cmds := []struct {
+		Command          *Cmd
+		ExitCode         int
+		NotFound         bool
+		PermissionDenied bool
+		NoMedium         bool
+	}{
+		{NewCommand(TypeNormal, "grep", "package command.go"), 1, false, false, false},
+		{NewCommand(TypeNormal, "rdlprmpf"), -1, true, false, false},
+		{NewCommand(TypeBash, "rdlprmpf"), 127, true, false, false},
+		{NewCommand(TypeBash, "echo 'sudo: a password is required' >&2; exit 1"), 1, false, true, false},
+		{NewCommand(TypeBash, "echo 'Error: Error opening /dev/sdc: No medium found' >&2; exit 1"), 1, false, false, true},
+	}
+
+	for _, c := range cmds {
+		t.Logf("Testing command '%s'\n", c.Command)
+		_, err := c.Command.Execute()
+
+		var commandError *CommandError
+		assert.True(t, errors.As(err, &commandError))
+		assert.Equal(t, c.Command.Args, commandError.Args)
+		assert.Equal(t, c.ExitCode, commandError.ExitCode)
+		assert.Equal(t, c.NotFound, IsNotFound(err))
+		assert.Equal(t, c.PermissionDenied, IsPermissionDenied(err))
+		assert.Equal(t, c.NoMedium, IsNoMedium(err))
+	}
+
+	stream, err := NewCommand(TypeBash, "echo progress >&2; exit 2").Start()
+	assert.NoError(t, err)
+	err = stream.Wait()
+	var commandError *CommandError
+	assert.True(t, errors.As(err, &commandError))
+	assert.Equal(t, 2, commandError.ExitCode)
+	assert.Equal(t, "progress\n", commandError.Stderr)
+	assert.Contains(t, err.Error(), "(progress)")
+}
+
+func TestCommandErrorReplay(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	replayer, err := NewReplayRunner("testData/replay_test/raspifix")
+	assert.NoError(t, err)
+	previous := SetRunner(replayer)
+	defer SetRunner(previous)
+
+	disk, err := NewPartedDisk(context.Background(), "sdc")
+	assert.Nil(t, disk)
+	assert.True(t, IsNoMedium(err))
+	assert.False(t, IsNotFound(err))
+}
@@ -0,0 +1,120 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// Escalation - strategy used to execute TypeSudo commands with root privileges
+type Escalation struct {
+	Name        string   // none, sudo or doas
+	Prefix      []string // prepended to the argv of TypeSudo commands
+	PreserveEnv string   // option followed by the names of the environment variables to pass to the command
+}
+
+func (e Escalation) String() string {
+	return e.Name
+}
+
+var (
+	// EscalationNone - already running as root
+	EscalationNone = &Escalation{Name: "none"}
+	// EscalationSudo - non interactive sudo
+	EscalationSudo = &Escalation{Name: "sudo", Prefix: []string{"sudo", "-n"}, PreserveEnv: "--preserve-env="}
+	// EscalationDoas - non interactive doas. doas has no option to keep environment variables, they have to be
+	// passed with setenv { LC_ALL LANG PATH } in doas.conf
+	EscalationDoas = &Escalation{Name: "doas", Prefix: []string{"doas", "-n"}}
+)
+
+// ErrNoEscalation - neither running as root nor sudo or doas available
+var ErrNoEscalation = errors.New("raspiBackup has to be started as root or sudo or doas have to be installed")
+
+var (
+	escalationMutex sync.Mutex
+	escalation      *Escalation
+)
+
+// ResolveEscalation - determines the escalation strategy of the current process: none if running as root,
+// otherwise sudo or doas, whichever is installed first
+func ResolveEscalation() (*Escalation, error) {
+
+	escalationMutex.Lock()
+	defer escalationMutex.Unlock()
+
+	if escalation != nil {
+		return escalation, nil
+	}
+
+	if os.Geteuid() == 0 {
+		escalation = EscalationNone
+	} else {
+		for _, e := range []*Escalation{EscalationSudo, EscalationDoas} {
+			if _, err := exec.LookPath(e.Prefix[0]); err == nil {
+				escalation = e
+				break
+			}
+		}
+	}
+
+	if escalation == nil {
+		return nil, ErrNoEscalation
+	}
+	tools.Logger.Debugf("Using escalation %s", escalation)
+	return escalation, nil
+}
+
+// SetEscalation - overrides the escalation strategy and returns the previous one
+func SetEscalation(e *Escalation) *Escalation {
+	escalationMutex.Lock()
+	defer escalationMutex.Unlock()
+	previous := escalation
+	escalation = e
+	return previous
+}
+
+// escalate - argv of a TypeSudo command. The environment of the command is set on the sudo or doas process, sudo resets
+// it and is asked to keep the variables of env. If no escalation is available the command is executed without and will
+// fail with a permission error
+func escalate(argv []string, env []string) []string {
+	e, err := ResolveEscalation()
+	if err != nil {
+		tools.Logger.Error(fmt.Sprintf("%s: %s", argv[0], err.Error()))
+		return argv
+	}
+	if len(e.Prefix) == 0 {
+		return argv
+	}
+	result := append([]string{}, e.Prefix...)
+	if names := variableNames(env); e.PreserveEnv != "" && len(names) > 0 {
+		result = append(result, e.PreserveEnv+strings.Join(names, ","))
+	}
+	return append(result, argv...)
+}
+
+// variableNames - names of the variables of an environment, e.g. LC_ALL for LC_ALL=C
+func variableNames(env []string) []string {
+	var result []string
+	seen := make(map[string]bool, len(env))
+	for _, v := range env {
+		name := strings.SplitN(v, "=", 2)[0]
+		if name != "" && !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	return result
+}
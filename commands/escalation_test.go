@@ -0,0 +1,58 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"os"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscalation(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	previous := SetEscalation(nil)
+	defer SetEscalation(previous)
+
+	if os.Geteuid() == 0 {
+		e, err := ResolveEscalation()
+		assert.NoError(t, err)
+		assert.Equal(t, EscalationNone, e)
+	}
+
+	escalations := []struct {
+		Escalation *Escalation
+		Args       []string
+	}{
+		{EscalationNone, []string{"parted", "-m", "/dev/sda"}},
+		{EscalationSudo, []string{"sudo", "-n", "--preserve-env=LC_ALL,LANG,PATH", "parted", "-m", "/dev/sda"}},
+		{EscalationDoas, []string{"doas", "-n", "parted", "-m", "/dev/sda"}},
+	}
+
+	for _, e := range escalations {
+		SetEscalation(e.Escalation)
+		c := NewCommand(TypeSudo, "parted", "-m", "/dev/sda")
+		assert.Equal(t, e.Args, c.Args)
+		// the environment is set on the sudo or doas process and not passed as arguments
+		assert.Equal(t, ControlledEnvironment, c.Env)
+		assert.Equal(t, []string{"-m", "/dev/sda"}, c.Arguments)
+		// fixtures don't depend on the escalation
+		assert.Equal(t, "parted_-m_dev_sda-70329f50.json", FixtureName(c))
+	}
+
+	// sudo resets the environment so variables of an inherited environment are kept only if it was configured
+	SetEscalation(EscalationSudo)
+	assert.Equal(t, []string{"sudo", "-n", "rsync", "-a"}, NewCommand(TypeSudo, "rsync", "-a").InheritEnvironment().Args)
+	SetInheritedEnvironment([]string{"RSYNC_RSH=ssh"})
+	defer SetInheritedEnvironment(nil)
+	assert.Equal(t, []string{"sudo", "-n", "--preserve-env=RSYNC_RSH", "rsync", "-a"}, NewCommand(TypeSudo, "rsync", "-a").InheritEnvironment().Args)
+}
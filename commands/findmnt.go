@@ -9,8 +9,9 @@ package commands
 //#######################################################################################################################
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -18,17 +19,20 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/framps/raspiBackupNext/sysfs"
 	"github.com/framps/raspiBackupNext/tools"
 )
 
 const notFound = "Not found"
 
+var legacyLoopPattern = regexp.MustCompile(`^/dev/loop(\d+)$`)
+
 // SystemDevice -
 type SystemDevice struct {
-	DeviceName      string // /dev/sda1, /dev/mmcblk3p2
-	Number          int    // 1, 2
-	Disk            string // sda, mmcblk3
-	PartitionName   string // sda1, mmcblk3p2
+	DeviceName      string // /dev/sda1, /dev/mmcblk3p2, /dev/nvme0n1p2
+	Number          int    // 1, 2, 0 for a whole device like /dev/md0
+	Disk            string // sda, mmcblk3, nvme0n1
+	PartitionName   string // sda, mmcblk3p, nvme0n1p
 	LocatedOnSDCard bool
 }
 
@@ -41,27 +45,25 @@ func NewSystemDevice(fullName string) (*SystemDevice, error) {
 
 	p := &SystemDevice{DeviceName: fullName, LocatedOnSDCard: false}
 
-	re := regexp.MustCompile("^/dev/([a-z]+)(([0-9]+)p)?([0-9]+)$")
-	matches := re.FindStringSubmatch(fullName)
-
-	if len(matches) != 5 {
+	name, err := ParseDeviceName(fullName)
+	if err != nil || !strings.HasPrefix(fullName, "/dev/") || name.IsHardwarePartition() {
 		e := fmt.Errorf("Illegal systempartition %s", fullName)
 		tools.Logger.Error(e)
 		return nil, e
 	}
 
-	// ["/dev/mmcblk3p1" "mmcblk" "3p" "3" "1"]
-	// ["/dev/sda3" "sda" "" "" "3"]
+	// /dev/mmcblk3p1: mmcblk3 p 1
+	// /dev/sda3: sda 3
 
-	p.Number, _ = strconv.Atoi(matches[4])
-	p.Disk = matches[1]
-	p.PartitionName = matches[1]
+	p.Number, p.Disk, p.PartitionName = name.Number, name.Disk, name.Disk+name.Infix
+	p.LocatedOnSDCard = strings.HasPrefix(name.Disk, "mmcblk")
 
-	if matches[1] == "mmcblk" {
-		p.Disk += matches[3]
-		p.PartitionName += matches[2]
-		p.LocatedOnSDCard = true
+	// /dev/loop7: loop 7, whole loop devices were always reported as partition of loop
+	if m := legacyLoopPattern.FindStringSubmatch(fullName); m != nil {
+		p.Number, _ = strconv.Atoi(m[1])
+		p.Disk, p.PartitionName = "loop", "loop"
 	}
+
 	return p, nil
 }
 
@@ -84,59 +86,127 @@ func (d SystemDevices) String() string {
 	return buffer.String()
 }
 
-// NewSystemDevices -
-func NewSystemDevices() (*SystemDevices, error) {
+// findmntArgs - findmnt reports the fields of /proc/self/mountinfo, -v omits the root of bind mounts in the source
+var findmntArgs = []string{"-J", "-l", "-v", "-o", "ID,PARENT,MAJ:MIN,FSROOT,TARGET,VFS-OPTIONS,FSTYPE,SOURCE,FS-OPTIONS"}
+
+// findmntJSONMount - mount of findmnt -J
+type findmntJSONMount struct {
+	ID         lsblkValue `json:"id"`
+	Parent     lsblkValue `json:"parent"`
+	MajMin     lsblkValue `json:"maj:min"`
+	Fsroot     lsblkValue `json:"fsroot"`
+	Target     lsblkValue `json:"target"`
+	VfsOptions lsblkValue `json:"vfs-options"`
+	Fstype     lsblkValue `json:"fstype"`
+	Source     lsblkValue `json:"source"`
+	FsOptions  lsblkValue `json:"fs-options"`
+}
 
-	var (
-		bootDevice string
-		rootDevice string
-	)
+/*
+{
+   "filesystems": [
+      {"id": 21, "parent": 1, "maj:min": "179:2", "fsroot": "/", "target": "/", "vfs-options": "rw,noatime", "fstype": "ext4", "source": "/dev/root", "fs-options": "rw"},
+      {"id": 30, "parent": 21, "maj:min": "179:1", "fsroot": "/", "target": "/boot/firmware", "vfs-options": "rw,relatime", "fstype": "vfat", "source": "/dev/mmcblk0p1", "fs-options": "rw,fmask=0022"}
+   ]
+}
+*/
+
+func parseFindmntJSON(data []byte) ([]*sysfs.Mount, error) {
+
+	var output struct {
+		Filesystems []findmntJSONMount `json:"filesystems"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
 
-	command := NewCommand(TypeSudo, "findmnt", "/boot", "-o", "source", "-n")
+	mounts := make([]*sysfs.Mount, 0, len(output.Filesystems))
+	for _, j := range output.Filesystems {
+		mounts = append(mounts, &sysfs.Mount{ID: int(j.ID.int64()), Parent: int(j.Parent.int64()), Dev: string(j.MajMin),
+			Root: string(j.Fsroot), Mountpoint: string(j.Target), Options: string(j.VfsOptions), FSType: string(j.Fstype),
+			Source: string(j.Source), SuperOptions: string(j.FsOptions)})
+	}
+	return mounts, nil
+}
+
+// NewMountTable - mount table of the system
+func NewMountTable(ctx context.Context) (*sysfs.MountTable, error) {
+
+	command := NewCommandContext(ctx, TypeSudo, "findmnt", findmntArgs...)
 	result, err := command.Execute()
 	if err != nil {
-		tools.Logger.Debugf("NewSystemDevices /boot failed: %s", err.Error())
-	} else {
-		rdr := strings.NewReader(string(*result))
-		scanner := bufio.NewScanner(rdr)
-		if scanner.Scan() {
-			bootDevice = scanner.Text()
-		} else {
-			bootDevice = notFound
-		}
+		tools.Logger.Errorf("NewMountTable failed: %s", err.Error())
+		return nil, err
+	}
+
+	mounts, err := parseFindmntJSON(*result)
+	if err != nil {
+		tools.Logger.Errorf("NewMountTable failed: %s", err.Error())
+		return nil, err
+	}
+	return sysfs.NewMountTable(mounts), nil
+}
+
+// NewMountTableFromFile - reads findmnt -J output or a copy of /proc/self/mountinfo
+func NewMountTableFromFile(fileName string) (*sysfs.MountTable, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
 	}
 
-	command = NewCommand(TypeSudo, "findmnt", "/", "-o", "source", "-n")
-	result, err = command.Execute()
+	var mounts []*sysfs.Mount
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "{") {
+		mounts, err = parseFindmntJSON(b)
+	} else {
+		mounts, err = sysfs.ParseMountinfo(bytes.NewReader(b))
+	}
 	if err != nil {
-		tools.Logger.Debugf("NewSystemDevices / failed: %s", err.Error())
+		return nil, err
+	}
+	return sysfs.NewMountTable(mounts), nil
+}
+
+// NewSystemDevicesFromMountTable - boot and root device of the system. The boot device is the vfat filesystem mounted
+// on /boot/firmware or /boot. A root filesystem on an encrypted or lvm device is located on the partition the device
+// is stacked on
+func NewSystemDevicesFromMountTable(table *sysfs.MountTable, devices *sysfs.Devices) *SystemDevices {
+
+	systemDevices := SystemDevices{}
+
+	if device := table.Device(table.Bootmount(), devices); device != "" {
+		systemDevices.Bootdevice, _ = NewSystemDevice(device)
+	} else {
+		tools.Logger.Debugf("NewSystemDevices: Boot device %s", notFound)
+	}
+	if device := table.Device(table.Find("/"), devices); device != "" {
+		systemDevices.Rootdevice, _ = NewSystemDevice(devices.Partition(device))
 	} else {
-		rdr := strings.NewReader(string(*result))
-		scanner := bufio.NewScanner(rdr)
-		if scanner.Scan() {
-			rootDevice = scanner.Text()
-		} else {
-			bootDevice = notFound
-		}
+		tools.Logger.Debugf("NewSystemDevices: Root device %s", notFound)
 	}
 
-	systemDevice := SystemDevices{}
-	bootSystemDevice, err := NewSystemDevice(bootDevice)
-	if err == nil {
-		systemDevice.Bootdevice = bootSystemDevice
+	return &systemDevices
+}
+
+// NewSystemDevices -
+func NewSystemDevices(ctx context.Context) (*SystemDevices, error) {
+
+	table, err := NewMountTable(ctx)
+	if err != nil {
+		return nil, err
 	}
-	rootSystemDevice, err := NewSystemDevice(rootDevice)
-	if err == nil {
-		systemDevice.Rootdevice = rootSystemDevice
+	lsblkDisks, err := NewLsblkDisks(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return &systemDevice, nil
+	return NewSystemDevicesFromMountTable(table, lsblkDisks.Devices()), nil
 }
 
 // NewSystemDevicesToFile -
-func NewSystemDevicesToFile(fileName string) error {
+func NewSystemDevicesToFile(ctx context.Context, fileName string) error {
 
-	b, err := NewSystemDevices()
+	b, err := NewSystemDevices(ctx)
 	if err != nil {
 		return err
 	}
@@ -11,11 +11,14 @@ package commands
 import (
 	"testing"
 
+	"github.com/framps/raspiBackupNext/tools"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestFindmnt(t *testing.T) {
 
+	tools.NewLogger(false)
+
 	cmds := []struct {
 		DeviceName      string // /dev/sda1, /dev/mmcblk3p2
 		Number          int    // 1, 2
@@ -28,8 +31,12 @@ func TestFindmnt(t *testing.T) {
 		{"/dev/sda1", 1, "sda", "sda", false, false},
 		{"/dev/mmcblk1p3", 3, "mmcblk1", "mmcblk1p", true, false},
 		{"/dev/loop7", 7, "loop", "loop", false, false},
+		{"/dev/loop0p2", 2, "loop0", "loop0p", false, false},
+		{"/dev/nvme0n1p2", 2, "nvme0n1", "nvme0n1p", false, false},
+		{"/dev/md127", 0, "md127", "md127", false, false},
 		// failures
 		{"loop7", 3, "loop", "loop", true, true},
+		{"/dev/mmcblk0boot0", 0, "mmcblk0boot0", "mmcblk0boot0", true, true},
 		{"/dev/578", 3, "loop", "loop7", true, true},
 		{"dev/sda", 3, "loop", "loop7", true, true},
 	}
@@ -52,3 +59,7 @@ func TestFindmnt(t *testing.T) {
 		}
 	}
 }
+
+func TestFindmntJSON(t *testing.T) {
+	VerifyData(t, Findmnt, "findmnt")
+}
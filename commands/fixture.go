@@ -0,0 +1,127 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// ErrNoFixture - returned by ReplayRunner if no fixture was recorded for a command
+var ErrNoFixture = errors.New("No fixture recorded")
+
+const maxFixtureNameLength = 64
+
+var fixtureNameCleaner = regexp.MustCompile(`[^A-Za-z0-9.=-]+`)
+
+// FixtureName - name of the fixture file of a command. The name is derived from the command as passed to NewCommand
+// and not from the effective argv so fixtures recorded on one system can be replayed on another one
+func FixtureName(c *Cmd) string {
+	key := strings.Join(append([]string{c.Type.String(), c.Command}, c.Arguments...), "\x00")
+	hash := sha1.Sum([]byte(key))
+
+	name := strings.Trim(fixtureNameCleaner.ReplaceAllString(c.Command+"_"+strings.Join(c.Arguments, "_"), "_"), "_")
+	if len(name) > maxFixtureNameLength {
+		name = name[:maxFixtureNameLength]
+	}
+	return fmt.Sprintf("%s-%x.json", name, hash[:4])
+}
+
+// RecordingRunner - executes commands with a delegate runner and saves each invocation as fixture in a directory
+type RecordingRunner struct {
+	Directory string
+	Delegate  Runner
+}
+
+// NewRecordingRunner -
+func NewRecordingRunner(directory string, delegate Runner) (*RecordingRunner, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	return &RecordingRunner{Directory: directory, Delegate: delegate}, nil
+}
+
+// Run -
+func (r *RecordingRunner) Run(c *Cmd) (*Invocation, error) {
+
+	invocation, err := r.Delegate.Run(c)
+	if invocation == nil {
+		return nil, err
+	}
+
+	j, jerr := json.MarshalIndent(invocation, "", " ")
+	if jerr == nil {
+		jerr = ioutil.WriteFile(filepath.Join(r.Directory, FixtureName(c)), j, 0644)
+	}
+	if jerr != nil {
+		tools.Logger.Errorf("Unable to record fixture for %s: %s", c, jerr.Error())
+	}
+
+	return invocation, err
+}
+
+// ReplayRunner - serves invocations previously saved by a RecordingRunner
+type ReplayRunner struct {
+	Directory string
+}
+
+// NewReplayRunner -
+func NewReplayRunner(directory string) (*ReplayRunner, error) {
+	fi, err := os.Stat(directory)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is no directory", directory)
+	}
+	return &ReplayRunner{Directory: directory}, nil
+}
+
+// Run -
+func (r *ReplayRunner) Run(c *Cmd) (*Invocation, error) {
+
+	ctx, cancel := c.Context()
+	defer cancel()
+	if err := c.contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	fileName := filepath.Join(r.Directory, FixtureName(c))
+	j, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for '%s' (%s)", ErrNoFixture, c, fileName)
+		}
+		return nil, err
+	}
+
+	var invocation Invocation
+	if err := json.Unmarshal(j, &invocation); err != nil {
+		return nil, fmt.Errorf("Invalid fixture %s: %s", fileName, err.Error())
+	}
+	// report the argv of the current system and not the recorded one
+	invocation.Args = c.Args
+
+	switch {
+	case invocation.Error != "":
+		return &invocation, errors.New(invocation.Error)
+	case invocation.ExitCode != 0:
+		return &invocation, &ExitStatusError{ExitCode: invocation.ExitCode}
+	}
+	return &invocation, nil
+}
@@ -11,6 +11,8 @@ package commands
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -20,18 +22,92 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/framps/raspiBackupNext/sysfs"
 	"github.com/framps/raspiBackupNext/tools"
 )
 
+// LsblkDevice - device stacked on a disk or partition, e.g. crypt, lvm or raid1
+type LsblkDevice struct {
+	Name        string
+	Path        string
+	MajMin      string
+	Type        string // crypt, lvm, raid1, ...
+	Size        int64
+	Fstype      string
+	Uuid        string
+	Label       string
+	Mountpoints []string
+	Children    []*LsblkDevice
+}
+
+func (d LsblkDevice) String() string {
+	return fmt.Sprintf("Holder: %s - MajMin: %s - Size: %d - Type: %s%s", d.Name, d.MajMin, d.Size, d.Type,
+		details("Fstype", d.Fstype, "Uuid", d.Uuid, "Label", d.Label, "Mountpoints", strings.Join(d.Mountpoints, ",")))
+}
+
+// details - " - key: value" for all non empty values
+func details(keyValues ...string) string {
+	var result bytes.Buffer
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if keyValues[i+1] != "" {
+			result.WriteString(fmt.Sprintf(" - %s: %s", keyValues[i], keyValues[i+1]))
+		}
+	}
+	return result.String()
+}
+
+// writeHolders - writes all holders of a disk or partition recursively
+func writeHolders(result *bytes.Buffer, hdr string, holders []*LsblkDevice) {
+	for _, h := range holders {
+		result.WriteString("\n")
+		result.WriteString(hdr)
+		result.WriteString(h.String())
+		writeHolders(result, hdr+h.Name+" - ", h.Children)
+	}
+}
+
 // LsblkDisk -
 type LsblkDisk struct {
 	Name       string
 	Partitions map[int]*LsblkPartition
+
+	// available from lsblk -J only
+	Path        string
+	MajMin      string
+	Type        string // disk or loop
+	Size        int64
+	Model       string
+	Serial      string
+	Tran        string // usb, sata, nvme, ...
+	Rota        bool
+	Hotplug     bool
+	Rm          bool
+	Ro          bool
+	Pttype      string // dos or gpt
+	Ptuuid      string
+	Fstype      string // filesystem on the whole disk
+	Uuid        string
+	Label       string
+	Mountpoints []string
+	Children    []*LsblkDevice // devices stacked directly on the disk, e.g. lvm
 }
 
 func (d LsblkDisk) String() string {
 	var result bytes.Buffer
 	hdr := fmt.Sprintf("DiskName: %s - ", d.Name)
+	if d.Path != "" {
+		result.WriteString(fmt.Sprintf("%sPath: %s - MajMin: %s - Type: %s - Size: %d - Rota: %t - Hotplug: %t - RM: %t - RO: %t%s\n",
+			hdr, d.Path, d.MajMin, d.Type, d.Size, d.Rota, d.Hotplug, d.Rm, d.Ro,
+			details("Model", d.Model, "Serial", d.Serial, "Tran", d.Tran, "PTType", d.Pttype, "PTUuid", d.Ptuuid,
+				"Fstype", d.Fstype, "Uuid", d.Uuid, "Label", d.Label, "Mountpoints", strings.Join(d.Mountpoints, ","))))
+	}
+
+	if len(d.Partitions) == 0 && len(d.Children) > 0 { // whole disk is used, e.g. as lvm physical volume
+		var holders bytes.Buffer
+		writeHolders(&holders, hdr, d.Children)
+		result.WriteString(strings.TrimPrefix(holders.String(), "\n"))
+		return result.String()
+	}
 	result.WriteString(hdr)
 
 	if len(d.Partitions) > 0 {
@@ -46,12 +122,14 @@ func (d LsblkDisk) String() string {
 
 		for i := range index {
 			result.WriteString(index[i].String())
+			writeHolders(&result, hdr+index[i].Name+" - ", index[i].Children)
 			if i < len(d.Partitions)-1 {
 				result.WriteString("\n")
 				result.WriteString(hdr)
 			}
 		}
 	}
+	writeHolders(&result, hdr, d.Children)
 	return result.String()
 }
 
@@ -77,12 +155,28 @@ type LsblkPartition struct {
 	Ro         string
 	Type       string
 	Mountpoint string
+
+	// available from lsblk -J only
+	Path        string
+	Partuuid    string
+	Partlabel   string
+	Parttype    string
+	Fstype      string
+	Uuid        string
+	Label       string
+	Mountpoints []string       // all mountpoints, e.g. of btrfs subvolumes
+	Children    []*LsblkDevice // devices stacked on the partition, e.g. crypt, lvm or raid1
 }
 
 func (p LsblkPartition) String() string {
 	var result bytes.Buffer
 	result.WriteString(fmt.Sprintf("PartitionName: %s - Number: %d - MajMin: %s - RM: %s - Size: %d - RO: %s - Type: %s - Mountpoint: %s",
 		p.Name, p.Number, p.MajMin, p.Rm, p.Size, p.Ro, p.Type, p.Mountpoint))
+	if len(p.Mountpoints) > 1 {
+		result.WriteString(details("Mountpoints", strings.Join(p.Mountpoints, ",")))
+	}
+	result.WriteString(details("Fstype", p.Fstype, "Uuid", p.Uuid, "Label", p.Label,
+		"Partuuid", p.Partuuid, "Partlabel", p.Partlabel, "Parttype", p.Parttype))
 	return result.String()
 }
 
@@ -116,20 +210,69 @@ func (d LsblkDisks) String() string {
 	return result.String()
 }
 
+// addHolders - adds all holders recursively
+func addHolders(devices *sysfs.Devices, parent string, holders []*LsblkDevice) {
+	for _, h := range holders {
+		path := h.Path
+		if path == "" {
+			path = "/dev/" + h.Name
+		}
+		devices.Add(path, h.MajMin, "", h.Uuid, h.Label)
+		devices.AddParent(path, parent)
+		addHolders(devices, path, h.Children)
+	}
+}
+
+// Devices - disks, partitions and their holders used to resolve mount sources and the partitions holders are stacked on
+func (d LsblkDisks) Devices() *sysfs.Devices {
+	devices := sysfs.NewDevices()
+	for _, disk := range d.Disks {
+		devices.Add("/dev/"+disk.Name, disk.MajMin, "", disk.Uuid, disk.Label)
+		addHolders(devices, "/dev/"+disk.Name, disk.Children)
+		for _, p := range disk.Partitions {
+			devices.Add("/dev/"+p.Name, p.MajMin, p.Partuuid, p.Uuid, p.Label)
+			addHolders(devices, "/dev/"+p.Name, p.Children)
+		}
+	}
+	return devices
+}
+
+// collectHolders - collects all holders recursively by path
+func collectHolders(result map[string]*LsblkDevice, holders []*LsblkDevice) {
+	for _, h := range holders {
+		result[h.Path] = h
+		collectHolders(result, h.Children)
+	}
+}
+
+// Holders - all devices stacked on disks and partitions by path, e.g. /dev/mapper/data-home. Available from lsblk -J only
+func (d LsblkDisks) Holders() map[string]*LsblkDevice {
+	result := make(map[string]*LsblkDevice)
+	for _, disk := range d.Disks {
+		collectHolders(result, disk.Children)
+		for _, p := range disk.Partitions {
+			collectHolders(result, p.Children)
+		}
+	}
+	return result
+}
+
 // NewLsblkDisks -
-func NewLsblkDisks() (*LsblkDisks, error) {
+func NewLsblkDisks(ctx context.Context) (*LsblkDisks, error) {
 
 	lsblkids := LsblkDisks{make(map[string]*LsblkDisk, 16)}
 
-	command := NewCommand(TypeSudo, "lsblk", "-r", "-n", "-b")
+	command := NewCommandContext(ctx, TypeSudo, "lsblk", "-J", "-b", "-O")
 	result, err := command.Execute()
 	if err != nil {
 		tools.Logger.Errorf("NewLsblkid failed: %s", err.Error())
 		return nil, err
 	}
 
-	rdr := strings.NewReader(string(*result))
-	lsblkids.parse(rdr)
+	if _, err := lsblkids.parseJSON(*result); err != nil {
+		tools.Logger.Errorf("NewLsblkid failed: %s", err.Error())
+		return nil, err
+	}
 
 	return &lsblkids, nil
 }
@@ -163,28 +306,40 @@ func (d *LsblkDisks) parse(reader io.Reader) *LsblkDisks {
 
 	scanner := bufio.NewScanner(reader)
 
-	re := regexp.MustCompile(`([[:alpha:]]+(?:\d+p)?)([\d]+)`) // sda or sda1 or mmcblk0 or mmcblk0p1
-
 	var disk *LsblkDisk
 
 	for scanner.Scan() {
 		line := scanner.Text()
 		elements := strings.Split(line, " ")
+		if len(elements) < 6 {
+			continue
+		}
 
 		if elements[5] == "disk" {
 			if disk != nil {
 				d.Disks[disk.Name] = disk
 			}
+			disk = nil
+			if name, err := ParseDeviceName(elements[0]); err == nil && name.IsHardwarePartition() {
+				continue
+			}
 			disk = NewLsblkDisk(elements[0])
 			continue
 		} else if elements[5] == "part" {
-			matches := re.FindStringSubmatch(elements[0])
-			partitionNumberString := matches[2]
-			partitionNumber, _ := strconv.Atoi(partitionNumberString)
+			name, err := ParseDeviceName(elements[0])
+			if err != nil || !name.IsPartition() || disk == nil {
+				tools.Logger.Errorf("Unable to retrieve partition number of %s", elements[0])
+				continue
+			}
+			partitionNumber := name.Number
 			partition := NewLsblkPartition()
 			size, _ := strconv.ParseInt(elements[3], 10, 64)
+			mountpoint := ""
+			if len(elements) > 6 {
+				mountpoint = unescapeRaw(strings.Join(elements[6:], " "))
+			}
 			partition.Name, partition.Number, partition.MajMin, partition.Rm, partition.Size, partition.Ro, partition.Type, partition.Mountpoint =
-				matches[1]+matches[2], partitionNumber, elements[1], elements[2], size, elements[4], elements[5], elements[6]
+				name.Name(), partitionNumber, elements[1], elements[2], size, elements[4], elements[5], mountpoint
 			disk.Partitions[partitionNumber] = partition
 		}
 
@@ -196,6 +351,173 @@ func (d *LsblkDisks) parse(reader io.Reader) *LsblkDisks {
 	return d
 }
 
+// unescapeRaw - lsblk -r escapes blanks and other unsafe characters as \xHH
+func unescapeRaw(value string) string {
+	return rawEscape.ReplaceAllStringFunc(value, func(hex string) string {
+		n, _ := strconv.ParseUint(hex[2:], 16, 8)
+		return string([]byte{byte(n)})
+	})
+}
+
+var rawEscape = regexp.MustCompile(`\\x[0-9a-fA-F]{2}`)
+
+// lsblkValue - lsblk -J reports all values as strings up to util-linux 2.36 and as numbers and booleans since 2.37
+type lsblkValue string
+
+// UnmarshalJSON -
+func (v *lsblkValue) UnmarshalJSON(b []byte) error {
+	switch s := string(b); {
+	case s == "null":
+		*v = ""
+	case strings.HasPrefix(s, `"`):
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+		*v = lsblkValue(str)
+	default:
+		*v = lsblkValue(s)
+	}
+	return nil
+}
+
+func (v lsblkValue) int64() int64 {
+	n, _ := strconv.ParseInt(string(v), 10, 64)
+	return n
+}
+
+func (v lsblkValue) bool() bool {
+	return v == "1" || v == "true"
+}
+
+// flag - "1" or "0" as reported by lsblk -r
+func (v lsblkValue) flag() string {
+	if v.bool() {
+		return "1"
+	}
+	return "0"
+}
+
+// lsblkJSONDevice - device of lsblk -J -b -O
+type lsblkJSONDevice struct {
+	Name        lsblkValue        `json:"name"`
+	Path        lsblkValue        `json:"path"`
+	MajMin      lsblkValue        `json:"maj:min"`
+	Type        lsblkValue        `json:"type"`
+	Size        lsblkValue        `json:"size"`
+	Rm          lsblkValue        `json:"rm"`
+	Ro          lsblkValue        `json:"ro"`
+	Rota        lsblkValue        `json:"rota"`
+	Hotplug     lsblkValue        `json:"hotplug"`
+	Model       lsblkValue        `json:"model"`
+	Serial      lsblkValue        `json:"serial"`
+	Tran        lsblkValue        `json:"tran"`
+	Pttype      lsblkValue        `json:"pttype"`
+	Ptuuid      lsblkValue        `json:"ptuuid"`
+	Partuuid    lsblkValue        `json:"partuuid"`
+	Partlabel   lsblkValue        `json:"partlabel"`
+	Parttype    lsblkValue        `json:"parttype"`
+	Fstype      lsblkValue        `json:"fstype"`
+	Uuid        lsblkValue        `json:"uuid"`
+	Label       lsblkValue        `json:"label"`
+	Mountpoint  lsblkValue        `json:"mountpoint"`
+	Mountpoints []lsblkValue      `json:"mountpoints"` // since util-linux 2.37
+	Children    []lsblkJSONDevice `json:"children"`
+}
+
+func (j lsblkJSONDevice) mountpoints() []string {
+	var result []string
+	for _, m := range j.Mountpoints {
+		if m != "" {
+			result = append(result, string(m))
+		}
+	}
+	if len(result) == 0 && j.Mountpoint != "" {
+		result = append(result, string(j.Mountpoint))
+	}
+	return result
+}
+
+// device - the device with all devices stacked on it
+func (j lsblkJSONDevice) device() *LsblkDevice {
+	return &LsblkDevice{Name: string(j.Name), Path: string(j.Path), MajMin: string(j.MajMin), Type: string(j.Type),
+		Size: j.Size.int64(), Fstype: string(j.Fstype), Uuid: string(j.Uuid), Label: string(j.Label),
+		Mountpoints: j.mountpoints(), Children: j.holders()}
+}
+
+// holders - devices stacked on the device including partitions of holders, e.g. md0p1 of a partitioned raid array
+func (j lsblkJSONDevice) holders() []*LsblkDevice {
+	var result []*LsblkDevice
+	for _, c := range j.Children {
+		result = append(result, c.device())
+	}
+	return result
+}
+
+/*
+{
+   "blockdevices": [
+      {"name": "sda", "kname": "sda", "path": "/dev/sda", "maj:min": "8:0", ..., "type": "disk", ...
+         "children": [
+            {"name": "sda1", "kname": "sda1", "path": "/dev/sda1", "maj:min": "8:1", ..., "type": "part", ...
+*/
+
+// parseJSON - parses the output of lsblk -J -b -O into a tree disk -> partition -> crypt/lvm/raid
+func (d *LsblkDisks) parseJSON(data []byte) (*LsblkDisks, error) {
+
+	var output struct {
+		Blockdevices []lsblkJSONDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	for _, j := range output.Blockdevices {
+		if j.Type != "disk" {
+			continue
+		}
+		if name, err := ParseDeviceName(string(j.Name)); err == nil && name.IsHardwarePartition() {
+			continue
+		}
+
+		disk := NewLsblkDisk(string(j.Name))
+		disk.Path, disk.MajMin, disk.Type, disk.Size = string(j.Path), string(j.MajMin), string(j.Type), j.Size.int64()
+		disk.Model, disk.Serial, disk.Tran = strings.TrimSpace(string(j.Model)), strings.TrimSpace(string(j.Serial)), string(j.Tran)
+		disk.Rota, disk.Hotplug, disk.Rm, disk.Ro = j.Rota.bool(), j.Hotplug.bool(), j.Rm.bool(), j.Ro.bool()
+		disk.Pttype, disk.Ptuuid = string(j.Pttype), string(j.Ptuuid)
+		disk.Fstype, disk.Uuid, disk.Label, disk.Mountpoints = string(j.Fstype), string(j.Uuid), string(j.Label), j.mountpoints()
+
+		for _, c := range j.Children {
+			if c.Type != "part" { // partitions of the disk are added as disk partitions
+				disk.Children = append(disk.Children, c.device())
+				continue
+			}
+			name, err := ParseDeviceName(string(c.Name))
+			if err != nil || !name.IsPartition() {
+				tools.Logger.Errorf("Unable to retrieve partition number of %s", c.Name)
+				continue
+			}
+			partition := NewLsblkPartition()
+			partition.Number = name.Number
+			partition.Name, partition.MajMin, partition.Rm, partition.Size, partition.Ro, partition.Type =
+				string(c.Name), string(c.MajMin), c.Rm.flag(), c.Size.int64(), c.Ro.flag(), string(c.Type)
+			partition.Mountpoints = c.mountpoints()
+			partition.Mountpoint = ""
+			if len(partition.Mountpoints) > 0 {
+				partition.Mountpoint = partition.Mountpoints[0]
+			}
+			partition.Path, partition.Partuuid, partition.Partlabel, partition.Parttype =
+				string(c.Path), string(c.Partuuid), string(c.Partlabel), string(c.Parttype)
+			partition.Fstype, partition.Uuid, partition.Label = string(c.Fstype), string(c.Uuid), string(c.Label)
+			partition.Children = c.holders()
+			disk.Partitions[partition.Number] = partition
+		}
+		d.Disks[disk.Name] = disk
+	}
+
+	return d, nil
+}
+
 // NewLsblkFromFile -
 func NewLsblkFromFile(fileName string) (*LsblkDisks, error) {
 
@@ -206,6 +528,10 @@ func NewLsblkFromFile(fileName string) (*LsblkDisks, error) {
 
 	blkid := LsblkDisks{make(map[string]*LsblkDisk, 16)}
 
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "{") {
+		return blkid.parseJSON(b)
+	}
+
 	rdr := strings.NewReader(string(b))
 	blkid.parse(rdr)
 
@@ -214,9 +540,9 @@ func NewLsblkFromFile(fileName string) (*LsblkDisks, error) {
 }
 
 // NewLsblkToFile -
-func NewLsblkToFile(fileName string) error {
+func NewLsblkToFile(ctx context.Context, fileName string) error {
 
-	b, err := NewLsblkDisks()
+	b, err := NewLsblkDisks(ctx)
 	if err != nil {
 		return err
 	}
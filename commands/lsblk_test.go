@@ -10,8 +10,30 @@ package commands
 
 import (
 	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLsblk(t *testing.T) {
 	VerifyData(t, Lsblkid, "lsblk")
 }
+
+func TestLsblkPartitionedRaid(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	disks, err := NewLsblkFromFile("testData/lsblk_test/nas-md-json.input")
+	assert.NoError(t, err)
+
+	holders := disks.Holders()
+	for _, path := range []string{"/dev/md0", "/dev/md0p1", "/dev/md0p2", "/dev/mapper/vault"} {
+		assert.Contains(t, holders, path)
+	}
+	assert.Equal(t, "part", holders["/dev/md0p1"].Type)
+
+	devices := disks.Devices()
+	assert.Equal(t, "/dev/md0p1", devices.ByUuid["a47c1e92-3d5b-4e8f-b160-9f2d7c4e8a35"])
+	assert.Equal(t, "/dev/md0", devices.Parents["/dev/md0p1"])
+	assert.Equal(t, "/dev/md0p2", devices.Parents["/dev/mapper/vault"])
+}
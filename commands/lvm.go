@@ -0,0 +1,205 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// LvmPhysicalVolume - physical volume reported by pvs
+type LvmPhysicalVolume struct {
+	Name        string // /dev/sdb1
+	VolumeGroup string // empty if the physical volume is not used
+	Size        int64
+	Free        int64
+	Uuid        string
+}
+
+func (p LvmPhysicalVolume) String() string {
+	return fmt.Sprintf("PV: %s - VG: %s - Size: %d - Free: %d - Uuid: %s", p.Name, p.VolumeGroup, p.Size, p.Free, p.Uuid)
+}
+
+// LvmVolumeGroup - volume group reported by vgs
+type LvmVolumeGroup struct {
+	Name       string
+	Size       int64
+	Free       int64
+	ExtentSize int64
+	Uuid       string
+}
+
+func (g LvmVolumeGroup) String() string {
+	return fmt.Sprintf("VG: %s - Size: %d - Free: %d - ExtentSize: %d - Uuid: %s", g.Name, g.Size, g.Free, g.ExtentSize, g.Uuid)
+}
+
+// LvmLogicalVolume - logical volume reported by lvs
+type LvmLogicalVolume struct {
+	Name        string
+	VolumeGroup string
+	Path        string // /dev/Backup/System, empty for thin pools
+	Size        int64
+	Attributes  string // -wi-ao----, first character is s for snapshots, t for thin pools and V for thin volumes
+	Origin      string // origin of a snapshot
+	Pool        string // thin pool of a thin volume
+	Uuid        string
+}
+
+func (l LvmLogicalVolume) String() string {
+	return fmt.Sprintf("LV: %s - VG: %s - Size: %d - Attributes: %s%s", l.Name, l.VolumeGroup, l.Size, l.Attributes,
+		details("Path", l.Path, "Origin", l.Origin, "Pool", l.Pool, "Uuid", l.Uuid))
+}
+
+// MapperName - device mapper name of the logical volume. Dashes in names are doubled, e.g. my--vg-root
+func (l LvmLogicalVolume) MapperName() string {
+	return strings.Replace(l.VolumeGroup, "-", "--", -1) + "-" + strings.Replace(l.Name, "-", "--", -1)
+}
+
+// IsSnapshot -
+func (l LvmLogicalVolume) IsSnapshot() bool {
+	return strings.HasPrefix(l.Attributes, "s") || l.Origin != ""
+}
+
+// Lvm - physical volumes, volume groups and logical volumes
+type Lvm struct {
+	PhysicalVolumes []*LvmPhysicalVolume
+	VolumeGroups    []*LvmVolumeGroup
+	LogicalVolumes  []*LvmLogicalVolume
+}
+
+func (l Lvm) String() string {
+	var result bytes.Buffer
+	for _, p := range l.PhysicalVolumes {
+		result.WriteString(p.String() + "\n")
+	}
+	for _, g := range l.VolumeGroups {
+		result.WriteString(g.String() + "\n")
+	}
+	for _, v := range l.LogicalVolumes {
+		result.WriteString(v.String() + "\n")
+	}
+	return result.String()
+}
+
+// lvmReportArgs - arguments of pvs, vgs and lvs to report columns as JSON with sizes in bytes
+func lvmReportArgs(columns string) []string {
+	return []string{"--reportformat", "json", "--units", "b", "--nosuffix", "-o", columns}
+}
+
+// lvmJSONReport - report of pvs, vgs and lvs --reportformat json. All values are strings
+type lvmJSONReport struct {
+	Report []struct {
+		Pv []map[string]lsblkValue `json:"pv"`
+		Vg []map[string]lsblkValue `json:"vg"`
+		Lv []map[string]lsblkValue `json:"lv"`
+	} `json:"report"`
+}
+
+/*
+  {
+      "report": [
+          {
+              "lv": [
+                  {"lv_name":"System", "vg_name":"Backup", "lv_path":"/dev/Backup/System", "lv_size":"329231892480", "lv_attr":"-wi-ao----", "origin":"", "pool_lv":"", "lv_uuid":"..."}
+              ]
+          }
+      ]
+  }
+*/
+
+// parse - parses one or more reports of pvs, vgs and lvs
+func (l *Lvm) parse(reader io.Reader) (*Lvm, error) {
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var report lvmJSONReport
+		if err := decoder.Decode(&report); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, r := range report.Report {
+			for _, p := range r.Pv {
+				l.PhysicalVolumes = append(l.PhysicalVolumes, &LvmPhysicalVolume{Name: string(p["pv_name"]), VolumeGroup: string(p["vg_name"]),
+					Size: p["pv_size"].int64(), Free: p["pv_free"].int64(), Uuid: string(p["pv_uuid"])})
+			}
+			for _, g := range r.Vg {
+				l.VolumeGroups = append(l.VolumeGroups, &LvmVolumeGroup{Name: string(g["vg_name"]), Size: g["vg_size"].int64(),
+					Free: g["vg_free"].int64(), ExtentSize: g["vg_extent_size"].int64(), Uuid: string(g["vg_uuid"])})
+			}
+			for _, v := range r.Lv {
+				l.LogicalVolumes = append(l.LogicalVolumes, &LvmLogicalVolume{Name: string(v["lv_name"]),
+					VolumeGroup: string(v["vg_name"]), Path: string(v["lv_path"]), Size: v["lv_size"].int64(),
+					Attributes: string(v["lv_attr"]), Origin: string(v["origin"]), Pool: string(v["pool_lv"]), Uuid: string(v["lv_uuid"])})
+			}
+		}
+	}
+
+	sort.Slice(l.PhysicalVolumes, func(i, j int) bool { return l.PhysicalVolumes[i].Name < l.PhysicalVolumes[j].Name })
+	sort.Slice(l.VolumeGroups, func(i, j int) bool { return l.VolumeGroups[i].Name < l.VolumeGroups[j].Name })
+	sort.Slice(l.LogicalVolumes, func(i, j int) bool {
+		return l.LogicalVolumes[i].VolumeGroup+"/"+l.LogicalVolumes[i].Name < l.LogicalVolumes[j].VolumeGroup+"/"+l.LogicalVolumes[j].Name
+	})
+
+	return l, nil
+}
+
+// NewLvm - retrieves all physical volumes, volume groups and logical volumes. If lvm2 is not installed no volumes are
+// returned
+func NewLvm(ctx context.Context) (*Lvm, error) {
+
+	var output bytes.Buffer
+
+	for _, report := range []struct {
+		command string
+		columns string
+	}{
+		{"pvs", "pv_name,vg_name,pv_size,pv_free,pv_uuid"},
+		{"vgs", "vg_name,vg_size,vg_free,vg_extent_size,vg_uuid"},
+		{"lvs", "lv_name,vg_name,lv_path,lv_size,lv_attr,origin,pool_lv,lv_uuid"},
+	} {
+		command := NewCommandContext(ctx, TypeSudo, report.command, lvmReportArgs(report.columns)...)
+		result, err := command.Execute()
+		if IsNotFound(err) {
+			tools.Logger.Debugf("NewLvm: lvm2 not installed")
+			return &Lvm{}, nil
+		}
+		if err != nil {
+			tools.Logger.Errorf("NewLvm failed: %s", err.Error())
+			return nil, err
+		}
+		output.Write(*result)
+	}
+
+	lvm, err := (&Lvm{}).parse(&output)
+	if err != nil {
+		tools.Logger.Errorf("NewLvm failed: %s", err.Error())
+		return nil, err
+	}
+	return lvm, nil
+}
+
+// NewLvmFromFile - reads the reports of pvs, vgs and lvs
+func NewLvmFromFile(fileName string) (*Lvm, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return (&Lvm{}).parse(bytes.NewReader(b))
+}
@@ -0,0 +1,51 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLvm(t *testing.T) {
+	VerifyData(t, LvmReports, "lvm")
+}
+
+func TestLvmReplay(t *testing.T) {
+
+	for _, system := range []string{"lvm", "raspifix"} {
+		replayer, err := NewReplayRunner("testData/replay_test/" + system)
+		assert.NoError(t, err)
+		previous := SetRunner(replayer)
+
+		t.Logf("Replaying %s\n", system)
+		lvm, err := NewLvm(context.Background())
+		SetRunner(previous)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		if system == "raspifix" { // lvm2 not installed
+			assert.Empty(t, lvm.PhysicalVolumes)
+			assert.Empty(t, lvm.LogicalVolumes)
+			continue
+		}
+		assert.Len(t, lvm.PhysicalVolumes, 1)
+		assert.Equal(t, "data", lvm.PhysicalVolumes[0].VolumeGroup)
+		assert.Equal(t, int64(4194304), lvm.VolumeGroups[0].ExtentSize)
+		assert.Len(t, lvm.LogicalVolumes, 2)
+		assert.Equal(t, "data-home", lvm.LogicalVolumes[0].MapperName())
+		assert.False(t, lvm.LogicalVolumes[0].IsSnapshot())
+	}
+
+	assert.Equal(t, "my--vg-root--fs", LvmLogicalVolume{Name: "root-fs", VolumeGroup: "my-vg"}.MapperName())
+	assert.True(t, LvmLogicalVolume{Attributes: "swi-a-s---", Origin: "root"}.IsSnapshot())
+}
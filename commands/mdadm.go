@@ -0,0 +1,141 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// MdDetailMember - member of a md array reported by mdadm --detail --export
+type MdDetailMember struct {
+	Device string // /dev/sda1
+	Role   string // slot number, spare, faulty or journal
+}
+
+// MdDetail - md array reported by mdadm --detail --export
+type MdDetail struct {
+	Device   string // /dev/md0
+	Level    string // raid1
+	Devices  int    // number of raid devices
+	Metadata string // 1.2
+	Uuid     string
+	Name     string // raspberrypi:0
+	Members  []*MdDetailMember
+}
+
+func (d MdDetail) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("MdDetail: %s - Level: %s - Devices: %d - Metadata: %s - Uuid: %s - Name: %s",
+		d.Device, d.Level, d.Devices, d.Metadata, d.Uuid, d.Name))
+	for _, m := range d.Members {
+		result.WriteString(fmt.Sprintf("\nMember: %s - Role: %s", m.Device, m.Role))
+	}
+	return result.String()
+}
+
+/*
+MD_LEVEL=raid1
+MD_DEVICES=2
+MD_METADATA=1.2
+MD_UUID=5f1c9a3e:8b2d4f60:a7e1c3b9:2d4f6a80
+MD_NAME=nas:0
+MD_DEVICE_dev_sda1_ROLE=0
+MD_DEVICE_dev_sda1_DEV=/dev/sda1
+MD_DEVICE_dev_sdb1_ROLE=faulty
+MD_DEVICE_dev_sdb1_DEV=/dev/sdb1
+*/
+
+func (d *MdDetail) parse(reader io.Reader) *MdDetail {
+
+	roles := make(map[string]string)
+	devices := make(map[string]string)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		key, value := line[:i], line[i+1:]
+		switch {
+		case key == "MD_LEVEL":
+			d.Level = value
+		case key == "MD_DEVICES":
+			d.Devices, _ = strconv.Atoi(value)
+		case key == "MD_METADATA":
+			d.Metadata = value
+		case key == "MD_UUID":
+			d.Uuid = value
+		case key == "MD_NAME":
+			d.Name = value
+		case strings.HasPrefix(key, "MD_DEVICE_") && strings.HasSuffix(key, "_ROLE"):
+			roles[strings.TrimSuffix(strings.TrimPrefix(key, "MD_DEVICE_"), "_ROLE")] = value
+		case strings.HasPrefix(key, "MD_DEVICE_") && strings.HasSuffix(key, "_DEV"):
+			devices[strings.TrimSuffix(strings.TrimPrefix(key, "MD_DEVICE_"), "_DEV")] = value
+		}
+	}
+
+	for key, device := range devices {
+		d.Members = append(d.Members, &MdDetailMember{Device: device, Role: roles[key]})
+	}
+	sort.Slice(d.Members, func(i, j int) bool {
+		return d.Members[i].Device < d.Members[j].Device
+	})
+	return d
+}
+
+// NewMdDetail - details of the md array device, e.g. /dev/md0
+func NewMdDetail(ctx context.Context, device string) (*MdDetail, error) {
+
+	command := NewCommandContext(ctx, TypeSudo, "mdadm", "--detail", "--export", device)
+	result, err := command.Execute()
+	if err != nil {
+		tools.Logger.Errorf("NewMdDetail failed for %s: %s", device, err.Error())
+		return nil, err
+	}
+
+	return (&MdDetail{Device: device}).parse(strings.NewReader(string(*result))), nil
+}
+
+// NewMdDetailFromFile -
+func NewMdDetailFromFile(fileName string) (*MdDetail, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return (&MdDetail{}).parse(bytes.NewReader(b)), nil
+}
+
+// NewMdstat - md arrays of /proc/mdstat. No arrays are returned if the md driver isn't loaded
+func NewMdstat(ctx context.Context) ([]*sysfs.MdArray, error) {
+
+	command := NewCommandContext(ctx, TypeNormal, "cat", "/proc/mdstat")
+	result, err := command.Execute()
+	if err != nil {
+		if classify(err, nil, nil, noSuchFilePattern) {
+			return nil, nil
+		}
+		tools.Logger.Errorf("NewMdstat failed: %s", err.Error())
+		return nil, err
+	}
+	return sysfs.ParseMdstat(bytes.NewReader(*result))
+}
@@ -0,0 +1,17 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"testing"
+)
+
+func TestMdadmDetail(t *testing.T) {
+	VerifyData(t, MdadmDetail, "mdadm")
+}
@@ -11,6 +11,7 @@ package commands
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -24,37 +25,60 @@ import (
 	"go.uber.org/zap"
 )
 
+// Partition kinds
+const (
+	KindPrimary  = "primary"
+	KindExtended = "extended" // msdos container of logical partitions
+	KindLogical  = "logical"  // msdos partition 5 and higher
+)
+
 // PartedPartition -
 type PartedPartition struct {
-	Name       string // /dev/sda1 or /dev/mmcblk0p1 or /dev/loop1
-	Number     int
-	Start      int64
-	End        int64
-	Size       int64
-	Type       string // ext4
-	FileSystem string
-	Flags      string // lba
+	Name          string // /dev/sda1 or /dev/mmcblk0p1 or /dev/loop1
+	Number        int
+	Start         int64
+	End           int64
+	Size          int64
+	Type          string // ext4
+	FileSystem    string
+	Flags         string // lba
+	PartitionName string // gpt only
+	Kind          string // primary, extended or logical
 }
 
 func (p PartedPartition) String() string {
-	return fmt.Sprintf("Partition: %6s Partitionnumber: %d Start: %d End: %d Size: %d Type: %s", p.Name, p.Number, p.Start, p.End, p.Size, p.Type)
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("Partition: %6s Partitionnumber: %d Start: %d End: %d Size: %d Type: %s Kind: %s", p.Name, p.Number, p.Start, p.End, p.Size, p.Type, p.Kind))
+	for _, kv := range [][2]string{{"Flags", p.Flags}, {"Name", p.PartitionName}} {
+		if kv[1] != "" {
+			result.WriteString(fmt.Sprintf(" %s: %s", kv[0], kv[1]))
+		}
+	}
+	return result.String()
 }
 
 // PartedDisk -
 type PartedDisk struct {
 	Name               string // /dev/sda or /dev/mmcblk0p or /dev/loop
 	Size               string // 1000GB
+	Transport          string // scsi, sd/mmc, nvme, ...
 	SectorSizeLogical  int    // 512
 	SectorSizePhysical int    // 512
-	PartitionTableType string // msdos
+	PartitionTableType string // msdos, gpt or unknown if the disk has no partition table
+	Model              string // Generic STORAGE DEVICE
+	Flags              string // pmbr_boot
 	Partitions         map[int]*PartedPartition
 }
 
 func (d PartedDisk) String() string {
 	var result bytes.Buffer
 
-	result.WriteString(fmt.Sprintf("Disk: %s Size: %s Sector size: %d/%d PartitiontableType: %s\n",
-		d.Name, d.Size, d.SectorSizeLogical, d.SectorSizePhysical, d.PartitionTableType))
+	result.WriteString(fmt.Sprintf("Disk: %s Size: %s Sector size: %d/%d PartitiontableType: %s Transport: %s Model: %s",
+		d.Name, d.Size, d.SectorSizeLogical, d.SectorSizePhysical, d.PartitionTableType, d.Transport, d.Model))
+	if d.Flags != "" {
+		result.WriteString(fmt.Sprintf(" Flags: %s", d.Flags))
+	}
+	result.WriteString("\n")
 
 	index := make([]*PartedPartition, 0, len(d.Partitions))
 	for _, partition := range d.Partitions {
@@ -62,7 +86,7 @@ func (d PartedDisk) String() string {
 	}
 
 	sort.Slice(index, func(i, j int) bool {
-		return index[i].Name < index[j].Name
+		return index[i].Number < index[j].Number
 	})
 
 	for _, partition := range index {
@@ -76,8 +100,47 @@ BYT;
 /dev/sde:15613952s:scsi:512:512:msdos:Generic STORAGE DEVICE:;
 1:8192s:93813s:85622s:fat16::lba;
 2:94208s:15613951s:15519744s:ext4::;
+
+BYT;
+/dev/nvme0n1:512110190592B:nvme:512:512:gpt:Samsung SSD 980 500GB:;
+1:1048576B:537919487B:536870912B:fat32:EFI System Partition:boot, esp;
+2:537919488B:512109142015B:511571222528B:ext4:rootfs:;
 */
 
+// splitMachine - splits a line of parted -m into its fields. parted escapes : and \ in the model and partition name
+func splitMachine(line string) []string {
+	line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+	var fields []string
+	var field bytes.Buffer
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case c == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// machineField - field i or empty if the line has less fields
+func machineField(fields []string, i int) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return ""
+}
+
+// machineSize - 8192s or 4194304B
+func machineSize(value string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimRight(value, "sBkMGT"), 10, 64)
+	return n
+}
+
 func (d *PartedDisk) parse(reader io.Reader) *PartedDisk {
 
 	scanner := bufio.NewScanner(reader)
@@ -88,11 +151,12 @@ func (d *PartedDisk) parse(reader io.Reader) *PartedDisk {
 	for scanner.Scan() {
 		line := scanner.Text()
 		if r.MatchString(line) {
-			parts := strings.Split(line, ":")
-			d.Name, d.Size, d.PartitionTableType = parts[0], parts[1], parts[5]
-			n, _ := strconv.Atoi(parts[3])
+			parts := splitMachine(line)
+			d.Name, d.Size, d.Transport, d.PartitionTableType = parts[0], machineField(parts, 1), machineField(parts, 2), machineField(parts, 5)
+			d.Model, d.Flags = machineField(parts, 6), machineField(parts, 7)
+			n, _ := strconv.Atoi(machineField(parts, 3))
 			d.SectorSizeLogical = n
-			n, _ = strconv.Atoi(parts[4])
+			n, _ = strconv.Atoi(machineField(parts, 4))
 			d.SectorSizePhysical = n
 			d.Partitions = make(map[int]*PartedPartition, 16)
 			break
@@ -105,24 +169,20 @@ func (d *PartedDisk) parse(reader io.Reader) *PartedDisk {
 	for scanner.Scan() {
 		line := scanner.Text()
 		if r.MatchString(line) {
-			parts := strings.Split(line, ":")
+			parts := splitMachine(line)
 			v, _ := strconv.Atoi(parts[0])
-			pInfix := ""
-			if tools.IsSpecialPartition(d.Name) {
-				pInfix = "p"
-			}
-			name := fmt.Sprintf("%s%s%d", d.Name, pInfix, v)
-			start, _ := strconv.ParseInt(parts[1][:len(parts[1])-1], 10, 64)
-			end, _ := strconv.ParseInt(parts[2][:len(parts[2])-1], 10, 64)
-			size, _ := strconv.ParseInt(parts[3][:len(parts[3])-1], 10, 64)
-			partition := PartedPartition{Name: name,
+			partition := PartedPartition{Name: PartitionName(d.Name, v),
 				Number:     v,
-				Start:      start,
-				End:        end,
-				Size:       size,
-				Type:       parts[4],
-				FileSystem: parts[5],
-				Flags:      parts[6][:len(parts[6])-1]}
+				Start:      machineSize(machineField(parts, 1)),
+				End:        machineSize(machineField(parts, 2)),
+				Size:       machineSize(machineField(parts, 3)),
+				Type:       machineField(parts, 4),
+				FileSystem: machineField(parts, 4),
+				Flags:      machineField(parts, 6),
+				Kind:       KindPrimary}
+			if d.PartitionTableType == "gpt" {
+				partition.PartitionName = machineField(parts, 5)
+			}
 			tools.Logger.Debug(zap.Any("Partition", partition))
 			d.Partitions[v] = &partition
 		} else {
@@ -130,11 +190,31 @@ func (d *PartedDisk) parse(reader io.Reader) *PartedDisk {
 		}
 	}
 
+	if d.PartitionTableType == "msdos" {
+		d.classifyMsdos()
+	}
+
 	return d
 }
 
+// classifyMsdos - partitions 5 and higher are logical partitions and the primary partition containing them is the
+// extended partition. A hybrid mbr is reported by parted as gpt so only real msdos partition tables are classified
+func (d *PartedDisk) classifyMsdos() {
+	for _, logical := range d.Partitions {
+		if logical.Number < 5 {
+			continue
+		}
+		logical.Kind = KindLogical
+		for _, primary := range d.Partitions {
+			if primary.Number < 5 && primary.Start <= logical.Start && logical.End <= primary.End {
+				primary.Kind = KindExtended
+			}
+		}
+	}
+}
+
 // NewPartedDisk -
-func NewPartedDisk(diskDeviceName string) (*PartedDisk, error) {
+func NewPartedDisk(ctx context.Context, diskDeviceName string) (*PartedDisk, error) {
 
 	disk := PartedDisk{Partitions: make(map[int]*PartedPartition, 16)}
 
@@ -145,19 +225,30 @@ func NewPartedDisk(diskDeviceName string) (*PartedDisk, error) {
 		diskName = diskDeviceName
 	}
 
-	//tools.IsSpecialPartition(diskDeviceName)
-	//re := regexp.MustCompile("^/dev/([a-z]+)(([0-9]+)p)?([0-9]+)$")
-
-	if false {
+	if name, err := ParseDeviceName(diskName); err == nil && name.IsPartition() {
 		err := fmt.Errorf("Invalid diskDeviceName %s", diskDeviceName)
 		tools.Logger.Errorf("NewDisk failed for %s: %s", diskName, err.Error())
 		return nil, err
 	}
 
-	command := NewCommand(TypeSudo, "parted", "-m", diskName, "unit", "B", "print")
+	command := NewCommandContext(ctx, TypeSudo, "parted", "-m", diskName, "unit", "B", "print")
 	result, err := command.Execute()
+	if err != nil && IsNoDiskLabel(err) {
+		// parted reports the disk even if it has no partition table
+		tools.Logger.Infof("No partition table on %s", diskName)
+		err = nil
+	}
 	if err != nil {
-		tools.Logger.Errorf("NewDisk failed for %s: %s", diskName, err.Error())
+		switch {
+		case IsNoMedium(err):
+			tools.Logger.Infof("No medium in %s", diskName)
+		case IsNotFound(err):
+			tools.Logger.Errorf("NewDisk failed for %s: parted not installed", diskName)
+		case IsPermissionDenied(err):
+			tools.Logger.Errorf("NewDisk failed for %s: no permission: %s", diskName, err.Error())
+		default:
+			tools.Logger.Errorf("NewDisk failed for %s: %s", diskName, err.Error())
+		}
 		return nil, err
 	}
 
@@ -188,9 +279,9 @@ func NewPartedFromFile(fileName string) (*PartedDisk, error) {
 }
 
 // NewPartedToFile -
-func NewPartedToFile(fileName, deviceName string) error {
+func NewPartedToFile(ctx context.Context, fileName, deviceName string) error {
 
-	b, err := NewPartedDisk(deviceName)
+	b, err := NewPartedDisk(ctx, deviceName)
 	if err != nil {
 		return err
 	}
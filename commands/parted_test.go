@@ -9,9 +9,29 @@ package commands
 //#######################################################################################################################
 
 import (
+	"context"
 	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestParted(t *testing.T) {
 	VerifyData(t, Parted, "parted")
 }
+
+func TestPartedNoDiskLabel(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	replayer, err := NewReplayRunner("testData/replay_test/nolabel")
+	assert.NoError(t, err)
+	previous := SetRunner(replayer)
+	defer SetRunner(previous)
+
+	disk, err := NewPartedDisk(context.Background(), "sdc")
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/sdc", disk.Name)
+	assert.Equal(t, "unknown", disk.PartitionTableType)
+	assert.Empty(t, disk.Partitions)
+}
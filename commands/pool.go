@@ -0,0 +1,93 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxWorkers - maximum number of disks probed concurrently by a parallel discovery
+var MaxWorkers = 4
+
+// Workers - number of workers of a discovery
+func Workers(parallelExecution bool) int {
+	if parallelExecution && MaxWorkers > 1 {
+		return MaxWorkers
+	}
+	return 1
+}
+
+// ForEach - calls probe with the index of each of n items. At most workers calls run concurrently, one worker calls
+// probe sequentially in item order. Items not started yet are skipped if ctx is done. Probes must only write data
+// owned by their item, e.g. the element of a slice with their index
+func ForEach(ctx context.Context, n, workers int, probe func(i int)) {
+
+	if workers <= 1 {
+		for i := 0; i < n && ctx.Err() == nil; i++ {
+			probe(i)
+		}
+		return
+	}
+
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				probe(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		items <- i
+	}
+	close(items)
+	wg.Wait()
+}
+
+// Timing - duration of a discovery step, e.g. lsblk or parted /dev/sda
+type Timing struct {
+	Step     string
+	Duration time.Duration
+}
+
+// Timings - durations of all steps of a discovery in the order they completed. Safe for concurrent use
+type Timings struct {
+	mutex sync.Mutex
+	steps []Timing
+}
+
+// Measure - adds the duration of step started at start
+func (t *Timings) Measure(step string, start time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.steps = append(t.steps, Timing{Step: step, Duration: time.Since(start)})
+}
+
+// Steps -
+func (t *Timings) Steps() []Timing {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]Timing(nil), t.steps...)
+}
+
+func (t *Timings) String() string {
+	var result bytes.Buffer
+	for _, s := range t.Steps() {
+		result.WriteString(fmt.Sprintf("%-30s %s\n", s.Step, s.Duration.Round(time.Microsecond)))
+	}
+	return result.String()
+}
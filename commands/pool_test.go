@@ -0,0 +1,63 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEach(t *testing.T) {
+
+	var order []int
+	ForEach(context.Background(), 5, 1, func(i int) { order = append(order, i) })
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+
+	var running, maxRunning int32
+	results := make([]int, 20)
+	ForEach(context.Background(), len(results), 3, func(i int) {
+		r := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if r <= m || atomic.CompareAndSwapInt32(&maxRunning, m, r) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+		atomic.AddInt32(&running, -1)
+	})
+	assert.Equal(t, int32(3), maxRunning)
+	for i, r := range results {
+		assert.Equal(t, i*i, r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	ForEach(ctx, 3, 2, func(i int) { called = true })
+	assert.False(t, called)
+
+	assert.Equal(t, 1, Workers(false))
+	assert.Equal(t, MaxWorkers, Workers(true))
+}
+
+func TestTimings(t *testing.T) {
+
+	timings := &Timings{}
+	ForEach(context.Background(), 4, 4, func(i int) {
+		timings.Measure("step", time.Now())
+	})
+	assert.Len(t, timings.Steps(), 4)
+	assert.Contains(t, timings.String(), "step ")
+}
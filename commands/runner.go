@@ -0,0 +1,129 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Invocation - result of one command execution
+type Invocation struct {
+	Type      CommandType
+	Command   string
+	Arguments []string
+	Args      []string // effective argv
+	ExitCode  int      // -1 if the command could not be started
+	Stdout    string
+	Stderr    string
+	Error     string `json:",omitempty"` // error message if the command could not be started
+}
+
+// Runner - executes commands. Run returns a non nil error if the command could not be started
+// or terminated with a non zero exit code. The invocation is returned in both cases if available.
+type Runner interface {
+	Run(c *Cmd) (*Invocation, error)
+}
+
+var (
+	runnerMutex sync.RWMutex
+	runner      Runner = ExecRunner{}
+)
+
+// SetRunner - replaces the runner used by all commands and returns the previous one
+func SetRunner(r Runner) Runner {
+	runnerMutex.Lock()
+	defer runnerMutex.Unlock()
+	previous := runner
+	runner = r
+	return previous
+}
+
+// CurrentRunner -
+func CurrentRunner() Runner {
+	runnerMutex.RLock()
+	defer runnerMutex.RUnlock()
+	return runner
+}
+
+// ExitStatusError - returned by runners which don't execute real processes if a command terminated with a non zero exit code
+type ExitStatusError struct {
+	ExitCode int
+}
+
+func (e *ExitStatusError) Error() string {
+	return fmt.Sprintf("exit status %d", e.ExitCode)
+}
+
+// waitDelay - time granted to a killed command to close its output before Run returns
+const waitDelay = 5 * time.Second
+
+// ExecRunner - executes commands on the local system. Each command runs in its own process group which is killed
+// when the command context is done
+type ExecRunner struct{}
+
+// Run -
+func (ExecRunner) Run(c *Cmd) (*Invocation, error) {
+
+	var stdout, stderr bytes.Buffer
+
+	ctx, cancel := c.Context()
+	defer cancel()
+
+	cmd := newExecCmd(ctx, c)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	invocation := newInvocation(c)
+	err := cmd.Run()
+	if ctxErr := c.contextError(ctx); ctxErr != nil {
+		err = ctxErr
+	}
+
+	invocation.Stdout, invocation.Stderr = stdout.String(), stderr.String()
+	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) {
+			invocation.ExitCode = exitError.ExitCode()
+		} else {
+			invocation.ExitCode = -1
+			invocation.Error = err.Error()
+		}
+	}
+	return invocation, err
+}
+
+func newExecCmd(ctx context.Context, c *Cmd) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Env = c.Env
+	if !c.inherit {
+		// resolve the executable with the fixed PATH and not the PATH of raspiBackup
+		if path, err := lookPath(c.Args[0], c.Env); err != nil {
+			cmd.Err = err
+		} else {
+			cmd.Path, cmd.Err = path, nil
+		}
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+func newInvocation(c *Cmd) *Invocation {
+	return &Invocation{Type: c.Type, Command: c.Command, Arguments: c.Arguments, Args: c.Args}
+}
@@ -0,0 +1,86 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplay(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	dir, err := ioutil.TempDir("", "raspiBackupFixtures")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder, err := NewRecordingRunner(dir, ExecRunner{})
+	assert.NoError(t, err)
+	previous := SetRunner(recorder)
+	defer SetRunner(previous)
+
+	recorded, err := NewCommand(TypeNormal, "echo", "Hello world").Execute()
+	assert.NoError(t, err)
+	_, err = NewCommand(TypeNormal, "grep", "package command.go").Execute()
+	assert.Error(t, err)
+
+	replayer, err := NewReplayRunner(dir)
+	assert.NoError(t, err)
+	SetRunner(replayer)
+
+	replayed, err := NewCommand(TypeNormal, "echo", "Hello world").Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, string(*recorded), string(*replayed))
+
+	_, err = NewCommand(TypeNormal, "grep", "package command.go").Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exit status 1")
+
+	_, err = NewCommand(TypeNormal, "echo", "Not recorded").Execute()
+	assert.True(t, errors.Is(err, ErrNoFixture))
+}
+
+func TestReplay(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	replayer, err := NewReplayRunner("testData/replay_test/raspifix")
+	assert.NoError(t, err)
+	previous := SetRunner(replayer)
+	defer SetRunner(previous)
+
+	lsblkDisks, err := NewLsblkDisks(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, lsblkDisks.Disks, 3)
+	assert.Equal(t, "usb", lsblkDisks.Disks["sda"].Tran)
+	assert.Equal(t, "/boot", lsblkDisks.Disks["mmcblk0"].Partitions[1].Mountpoint)
+
+	blkidDisks, err := NewBlkidDisks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "silver", blkidDisks.Disks["/dev/sda"].Partitions[1].Label)
+
+	for name := range lsblkDisks.Disks {
+		partedDisk, err := NewPartedDisk(context.Background(), name)
+		assert.NoError(t, err)
+		assert.Equal(t, "/dev/"+name, partedDisk.Name)
+		assert.Equal(t, "msdos", partedDisk.PartitionTableType)
+	}
+
+	systemDevices, err := NewSystemDevices(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/mmcblk0p1", systemDevices.Bootdevice.DeviceName)
+	assert.Equal(t, "/dev/mmcblk0p2", systemDevices.Rootdevice.DeviceName)
+}
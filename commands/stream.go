@@ -0,0 +1,230 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// maxStderrSize - number of trailing stderr bytes kept per command
+const maxStderrSize = 64 * 1024
+
+// tailBuffer - keeps the last maxStderrSize bytes written
+type tailBuffer struct {
+	sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > maxStderrSize {
+		b.data = b.data[len(b.data)-maxStderrSize:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return string(b.data)
+}
+
+// Pipeline - commands whose stdout is connected to the stdin of the next command, e.g. dd | gzip
+type Pipeline struct {
+	Commands []*Cmd
+	Stdin    io.Reader // stdin of the first command, nil: no input
+	Stdout   io.Writer // stdout of the last command, nil: available as Stream.Stdout
+}
+
+// NewPipeline -
+func NewPipeline(commands ...*Cmd) *Pipeline {
+	return &Pipeline{Commands: commands}
+}
+
+func (p Pipeline) String() string {
+	parts := make([]string, 0, len(p.Commands))
+	for _, c := range p.Commands {
+		parts = append(parts, c.String())
+	}
+	return strings.Join(parts, " | ")
+}
+
+// PipelineError - returned by Stream.Wait if at least one stage of a pipeline failed. Like with bash's pipefail
+// the rightmost failing stage is reported because failures of the previous stages are usually caused by it, e.g. SIGPIPE
+type PipelineError struct {
+	Pipeline string
+	Stage    int     // index of the reported stage
+	Errors   []error // errors of all stages, nil for successful stages
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("Stage %d of pipeline '%s' failed: %s", e.Stage+1, e.Pipeline, e.Errors[e.Stage])
+}
+
+// Unwrap -
+func (e *PipelineError) Unwrap() error {
+	return e.Errors[e.Stage]
+}
+
+type stage struct {
+	command *Cmd
+	start   time.Time
+	cmd     *exec.Cmd
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stderr  *tailBuffer
+}
+
+// Stream - started command or pipeline. Stdout has to be consumed completely before Wait is called
+type Stream struct {
+	Stdout   io.ReadCloser // stdout of the last command, nil if redirected to Pipeline.Stdout
+	pipeline string
+	stages   []*stage
+}
+
+// Stderr - trailing stderr output of all commands
+func (s *Stream) Stderr() string {
+	parts := make([]string, 0, len(s.stages))
+	for _, st := range s.stages {
+		parts = append(parts, st.stderr.String())
+	}
+	return strings.Join(parts, "")
+}
+
+// Wait - waits for all commands to terminate and returns the *CommandError of the command or a PipelineError
+func (s *Stream) Wait() error {
+
+	errs := make([]error, len(s.stages))
+	failed := -1
+	for i, st := range s.stages {
+		err := st.cmd.Wait()
+		if ctxErr := st.command.contextError(st.ctx); ctxErr != nil {
+			err = ctxErr
+		}
+		st.cancel()
+		if err != nil {
+			err = newCommandError(st.command, 0, st.stderr.String(), time.Since(st.start), err)
+			tools.Logger.Errorf("Command '%s' failed: %s", st.command, err)
+			errs[i], failed = err, i
+		}
+	}
+
+	switch {
+	case failed < 0:
+		return nil
+	case len(s.stages) == 1:
+		return errs[0]
+	default:
+		return &PipelineError{Pipeline: s.pipeline, Stage: failed, Errors: errs}
+	}
+}
+
+// Start - starts the command. Its stdout is available as Stream.Stdout, stderr is captured separately
+func (c *Cmd) Start() (*Stream, error) {
+	return NewPipeline(c).Start()
+}
+
+// Start - starts all commands of the pipeline. Stdout of a command is passed to the next command without copying
+func (p *Pipeline) Start() (*Stream, error) {
+
+	mutating := false
+	for _, c := range p.Commands {
+		mutating = mutating || c.IsMutating()
+	}
+	if skipDryRun(p.String(), mutating) {
+		return dryRunStream(p), nil
+	}
+
+	stream := &Stream{pipeline: p.String()}
+	tools.Logger.Debug("Starting pipeline ", stream.pipeline)
+
+	var (
+		stdin     io.Reader = p.Stdin
+		readPipe  *os.File  // read end of the previous pipe, owned by the parent until the next command is started
+		writePipe *os.File  // write end of the current pipe, owned by the parent until the command is started
+	)
+
+	abort := func(err error) (*Stream, error) {
+		for _, f := range []*os.File{readPipe, writePipe} {
+			if f != nil {
+				f.Close()
+			}
+		}
+		for _, st := range stream.stages {
+			st.cancel()
+			st.cmd.Wait()
+		}
+		if stream.Stdout != nil {
+			stream.Stdout.Close()
+		}
+		return nil, err
+	}
+
+	for i, c := range p.Commands {
+		ctx, cancel := c.Context()
+		st := &stage{command: c, start: time.Now(), ctx: ctx, cancel: cancel, stderr: &tailBuffer{}}
+		st.cmd = newExecCmd(ctx, c)
+		st.cmd.Stdin = stdin
+		st.cmd.Stderr = st.stderr
+		if c.Stderr != nil {
+			st.cmd.Stderr = io.MultiWriter(st.stderr, c.Stderr)
+		}
+
+		last := i == len(p.Commands)-1
+		var nextStdin *os.File
+		if !last || p.Stdout == nil {
+			r, w, err := os.Pipe()
+			if err != nil {
+				cancel()
+				return abort(err)
+			}
+			writePipe, st.cmd.Stdout = w, w
+			if last {
+				stream.Stdout = r
+			} else {
+				nextStdin = r
+			}
+		} else {
+			st.cmd.Stdout = p.Stdout
+		}
+
+		if err := st.cmd.Start(); err != nil {
+			cancel()
+			if nextStdin != nil {
+				nextStdin.Close()
+			}
+			return abort(newCommandError(c, 0, "", time.Since(st.start), err))
+		}
+		stream.stages = append(stream.stages, st)
+
+		// the child owns its copies of the pipe ends now
+		for _, f := range []*os.File{readPipe, writePipe} {
+			if f != nil {
+				f.Close()
+			}
+		}
+		readPipe, writePipe = nextStdin, nil
+		if nextStdin != nil {
+			stdin = nextStdin
+		}
+	}
+
+	return stream, nil
+}
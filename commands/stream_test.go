@@ -0,0 +1,88 @@
+package commands
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamStderr(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	stream, err := NewCommand(TypeBash, "echo out; echo err >&2").Start()
+	assert.NoError(t, err)
+	stdout, err := ioutil.ReadAll(stream.Stdout)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Wait())
+	assert.Equal(t, "out\n", string(stdout))
+	assert.Equal(t, "err\n", stream.Stderr())
+
+	result, err := NewCommand(TypeBash, "echo out; echo err >&2").Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "out\n", string(*result))
+}
+
+func TestPipeline(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	var target bytes.Buffer
+	pipeline := NewPipeline(
+		NewCommand(TypeNormal, "printf", `a\nb\nc\n`),
+		NewCommand(TypeNormal, "gzip", "-c"),
+		NewCommand(TypeNormal, "gzip", "-d", "-c"),
+		NewCommand(TypeNormal, "grep", "b"))
+	pipeline.Stdout = &target
+
+	stream, err := pipeline.Start()
+	assert.NoError(t, err)
+	assert.Nil(t, stream.Stdout)
+	assert.NoError(t, stream.Wait())
+	assert.Equal(t, "b\n", target.String())
+
+	pipeline = NewPipeline(NewCommand(TypeNormal, "cat"), NewCommand(TypeNormal, "wc", "-c"))
+	pipeline.Stdin = strings.NewReader("Hello world")
+	stream, err = pipeline.Start()
+	assert.NoError(t, err)
+	stdout, _ := ioutil.ReadAll(stream.Stdout)
+	assert.NoError(t, stream.Wait())
+	assert.Equal(t, "11", strings.TrimSpace(string(stdout)))
+}
+
+func TestPipelineExitStatus(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	for stage, pipeline := range []*Pipeline{
+		NewPipeline(NewCommand(TypeBash, "echo x; exit 3"), NewCommand(TypeNormal, "cat"), NewCommand(TypeNormal, "cat")),
+		NewPipeline(NewCommand(TypeNormal, "echo", "x"), NewCommand(TypeBash, "cat; exit 3"), NewCommand(TypeNormal, "cat")),
+		NewPipeline(NewCommand(TypeNormal, "echo", "x"), NewCommand(TypeNormal, "cat"), NewCommand(TypeBash, "cat; exit 3")),
+	} {
+		stream, err := pipeline.Start()
+		assert.NoError(t, err)
+		ioutil.ReadAll(stream.Stdout)
+		err = stream.Wait()
+
+		var pipelineError *PipelineError
+		assert.True(t, errors.As(err, &pipelineError))
+		assert.Equal(t, stage, pipelineError.Stage)
+		assert.Contains(t, err.Error(), "exit status 3")
+	}
+
+	_, err := NewPipeline(NewCommand(TypeNormal, "echo", "x"), NewCommand(TypeNormal, "rdlprmpf")).Start()
+	assert.Error(t, err)
+}
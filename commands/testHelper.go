@@ -28,6 +28,16 @@ const (
 	Lsblkid
 	// Parted -
 	Parted
+	// Findmnt -
+	Findmnt
+	// LvmReports -
+	LvmReports
+	// Cryptsetup -
+	Cryptsetup
+	// MdadmDetail -
+	MdadmDetail
+	// BtrfsSubvolumes -
+	BtrfsSubvolumes
 )
 
 // CommandFromFile -
@@ -43,6 +53,21 @@ func CommandFromFile(t TestCommandType, fileName string) (string, error) {
 	case Parted:
 		r, e := NewPartedFromFile(fileName)
 		return r.String(), e
+	case Findmnt:
+		r, e := NewMountTableFromFile(fileName)
+		return r.String(), e
+	case LvmReports:
+		r, e := NewLvmFromFile(fileName)
+		return r.String(), e
+	case Cryptsetup:
+		r, e := NewCryptStatusFromFile(fileName)
+		return r.String(), e
+	case MdadmDetail:
+		r, e := NewMdDetailFromFile(fileName)
+		return r.String(), e
+	case BtrfsSubvolumes:
+		r, e := NewBtrfsFromFile(fileName)
+		return r.String(), e
 	}
 	return "", nil
 }
@@ -0,0 +1,26 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const btrfsSuperblock = 0x10000
+
+// probeBtrfs - primary superblock at 64KiB
+func probeBtrfs(r io.ReaderAt) *Filesystem {
+	sb := read(r, btrfsSuperblock, 0x1000)
+	if sb == nil || string(sb[0x40:0x48]) != "_BHRfS_M" {
+		return nil
+	}
+	return &Filesystem{Type: TypeBtrfs, Uuid: uuid(sb[0x20:0x30]), Label: cstring(sb[0x12b : 0x12b+256]),
+		BlockSize: int(binary.LittleEndian.Uint32(sb[0x90:]))}
+}
@@ -0,0 +1,53 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	extSuperblock = 1024
+	extMagic      = 0xef53
+
+	extCompatHasJournal   = 0x0004
+	extIncompatJournalDev = 0x0008
+
+	// features supported by ext2 and ext3, all other features require ext4
+	extIncompatExt3 = 0x0002 | 0x0004 | 0x0010 // filetype, recover, meta_bg
+	extRoCompatExt3 = 0x0001 | 0x0002 | 0x0004 // sparse_super, large_file, btree_dir
+)
+
+// probeExt - ext2, ext3 and ext4. The type is derived from the feature flags like blkid does
+func probeExt(r io.ReaderAt) *Filesystem {
+
+	sb := read(r, extSuperblock, 1024)
+	if sb == nil || binary.LittleEndian.Uint16(sb[0x38:]) != extMagic {
+		return nil
+	}
+
+	compat := binary.LittleEndian.Uint32(sb[0x5c:])
+	incompat := binary.LittleEndian.Uint32(sb[0x60:])
+	roCompat := binary.LittleEndian.Uint32(sb[0x64:])
+	if incompat&extIncompatJournalDev != 0 { // external journal
+		return nil
+	}
+
+	fs := &Filesystem{Uuid: uuid(sb[0x68:0x78]), Label: cstring(sb[0x78:0x88]), BlockSize: 1024 << binary.LittleEndian.Uint32(sb[0x18:])}
+	switch {
+	case incompat&^extIncompatExt3 != 0 || roCompat&^extRoCompatExt3 != 0:
+		fs.Type = TypeExt4
+	case compat&extCompatHasJournal != 0:
+		fs.Type, fs.SecType = TypeExt3, TypeExt2
+	default:
+		fs.Type = TypeExt2
+	}
+	return fs
+}
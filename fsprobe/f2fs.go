@@ -0,0 +1,29 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	f2fsSuperblock = 1024
+	f2fsMagic      = 0xf2f52010
+)
+
+// probeF2fs - the label is UTF-16LE
+func probeF2fs(r io.ReaderAt) *Filesystem {
+	sb := read(r, f2fsSuperblock, 0x47c)
+	if sb == nil || binary.LittleEndian.Uint32(sb[0:]) != f2fsMagic {
+		return nil
+	}
+	return &Filesystem{Type: TypeF2fs, Uuid: uuid(sb[0x6c:0x7c]), Label: utf16le(sb[0x7c:0x47c]),
+		BlockSize: 1 << binary.LittleEndian.Uint32(sb[16:])}
+}
@@ -0,0 +1,40 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func f2fsImage() []byte {
+	image := make([]byte, 4096)
+	sb := image[f2fsSuperblock:]
+	binary.LittleEndian.PutUint32(sb[0:], f2fsMagic)
+	binary.LittleEndian.PutUint32(sb[16:], 12)
+	copy(sb[0x6c:], rawUUID())
+	putUTF16(sb[0x7c:], "flash")
+	return image
+}
+
+func TestProbeF2fs(t *testing.T) {
+
+	fs := probeF2fs(bytes.NewReader(f2fsImage()))
+	if assert.NotNil(t, fs) {
+		assert.Equal(t, Filesystem{Type: TypeF2fs, Uuid: testUUID, Label: "flash", BlockSize: 4096}, *fs)
+	}
+
+	image := f2fsImage()
+	binary.LittleEndian.PutUint32(image[f2fsSuperblock:], 0)
+	assert.Nil(t, probeF2fs(bytes.NewReader(image)))
+	assert.Nil(t, probeF2fs(bytes.NewReader(image[:2048])))
+}
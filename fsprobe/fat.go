@@ -0,0 +1,83 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// probeVfat - FAT12, FAT16 and FAT32. The label is taken from the boot sector
+func probeVfat(r io.ReaderAt) *Filesystem {
+
+	bs := read(r, 0, 512)
+	if bs == nil || bs[510] != 0x55 || bs[511] != 0xaa || (bs[0] != 0xeb && bs[0] != 0xe9) {
+		return nil
+	}
+	bytesPerSector := binary.LittleEndian.Uint16(bs[11:])
+	if bytesPerSector < 512 || bytesPerSector > 4096 || bytesPerSector&(bytesPerSector-1) != 0 || bs[13] == 0 {
+		return nil
+	}
+
+	fs := &Filesystem{Type: TypeVfat, BlockSize: int(bytesPerSector)}
+	var ebpb []byte // extended bios parameter block
+	switch {
+	case strings.HasPrefix(string(bs[82:90]), "FAT32"):
+		fs.Version, ebpb = "FAT32", bs[64:]
+	case strings.HasPrefix(string(bs[54:62]), "FAT1"):
+		fs.Version, fs.SecType, ebpb = cstring(bs[54:62]), "msdos", bs[36:]
+	default:
+		return nil
+	}
+
+	if ebpb[2] == 0x29 { // volume id and label are valid
+		fs.Uuid = serial(binary.LittleEndian.Uint32(ebpb[3:]))
+		if label := cstring(ebpb[7:18]); label != "NO NAME" {
+			fs.Label = label
+		}
+	}
+	return fs
+}
+
+const (
+	exfatEntrySize  = 32
+	exfatEntryLabel = 0x83
+)
+
+// probeExfat - exfat stores the label in the root directory
+func probeExfat(r io.ReaderAt) *Filesystem {
+
+	bs := read(r, 0, 512)
+	if bs == nil || string(bs[3:11]) != "EXFAT   " {
+		return nil
+	}
+
+	bytesPerSectorShift, sectorsPerClusterShift := uint(bs[108]), uint(bs[109])
+	if bytesPerSectorShift < 9 || bytesPerSectorShift > 12 || sectorsPerClusterShift > 25-bytesPerSectorShift {
+		return nil
+	}
+	fs := &Filesystem{Type: TypeExfat, Uuid: serial(binary.LittleEndian.Uint32(bs[100:])), BlockSize: 1 << bytesPerSectorShift}
+
+	heap := int64(binary.LittleEndian.Uint32(bs[88:]))
+	rootCluster := int64(binary.LittleEndian.Uint32(bs[96:]))
+	clusterSize := 1 << (bytesPerSectorShift + sectorsPerClusterShift)
+	root := read(r, (heap<<bytesPerSectorShift)+(rootCluster-2)*int64(clusterSize), clusterSize)
+	for i := 0; root != nil && i+exfatEntrySize <= len(root) && root[i] != 0; i += exfatEntrySize {
+		if root[i] == exfatEntryLabel {
+			n := int(root[i+1])
+			if n > 11 {
+				n = 11
+			}
+			fs.Label = utf16le(root[i+2 : i+2+2*n])
+			break
+		}
+	}
+	return fs
+}
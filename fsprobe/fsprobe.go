@@ -0,0 +1,126 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// Filesystem types, same as reported by blkid
+const (
+	TypeExt2  = "ext2"
+	TypeExt3  = "ext3"
+	TypeExt4  = "ext4"
+	TypeVfat  = "vfat"
+	TypeExfat = "exfat"
+	TypeBtrfs = "btrfs"
+	TypeF2fs  = "f2fs"
+	TypeXfs   = "xfs"
+	TypeSwap  = "swap"
+	TypeLUKS  = "crypto_LUKS"
+)
+
+// ErrUnknownFilesystem - no known superblock found
+var ErrUnknownFilesystem = errors.New("Unknown filesystem")
+
+// Filesystem - filesystem detected by its superblock
+type Filesystem struct {
+	Type      string // ext4, vfat, ... as reported by blkid
+	SecType   string // msdos for FAT12 and FAT16, ext2 for ext3
+	Version   string // FAT32, LUKS version, ...
+	Uuid      string // format as reported by blkid, e.g. 3312-932F for vfat
+	Label     string
+	BlockSize int
+}
+
+func (f Filesystem) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("Type: %s Uuid: %s Label: %s BlockSize: %d", f.Type, f.Uuid, f.Label, f.BlockSize))
+	if f.SecType != "" {
+		result.WriteString(fmt.Sprintf(" SecType: %s", f.SecType))
+	}
+	if f.Version != "" {
+		result.WriteString(fmt.Sprintf(" Version: %s", f.Version))
+	}
+	return result.String()
+}
+
+// prober - returns nil if the superblock doesn't match
+type prober func(r io.ReaderAt) *Filesystem
+
+// probers - LUKS first because a LUKS header may follow a stale superblock, vfat after exfat which also has a boot
+// sector signature
+var probers = []prober{probeLUKS, probeXfs, probeExfat, probeVfat, probeExt, probeF2fs, probeBtrfs, probeSwap}
+
+// Probe - detects the filesystem of a partition, device or image of a partition. Use io.NewSectionReader to probe
+// a partition inside an image of a disk
+func Probe(r io.ReaderAt) (*Filesystem, error) {
+	for _, p := range probers {
+		if fs := p(r); fs != nil {
+			return fs, nil
+		}
+	}
+	return nil, ErrUnknownFilesystem
+}
+
+// ProbeFile - detects the filesystem of a device, e.g. /dev/sda1, or an image file
+func ProbeFile(fileName string) (*Filesystem, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Probe(f)
+}
+
+// read - n bytes at offset or nil if they cannot be read, e.g. because the image is too small
+func read(r io.ReaderAt, offset int64, n int) []byte {
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return nil
+	}
+	return b
+}
+
+// uuid - 16 bytes in standard byte order
+func uuid(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// serial - 32 bit volume serial number of fat filesystems
+func serial(n uint32) string {
+	return fmt.Sprintf("%04X-%04X", n>>16, n&0xffff)
+}
+
+// cstring - string terminated by 0 or padded with blanks
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimRight(string(b), " ")
+}
+
+// utf16le - UTF-16LE string terminated by 0
+func utf16le(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := uint16(b[i]) | uint16(b[i+1])<<8
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,133 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testUUID = "64a5e86f-5ed3-4c9f-aab3-c4ae24bff95a"
+
+func rawUUID() []byte {
+	b, _ := hex.DecodeString(strings.Replace(testUUID, "-", "", -1))
+	return b
+}
+
+func putUTF16(b []byte, s string) {
+	for i, c := range utf16.Encode([]rune(s)) {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+}
+
+func extImage(compat, incompat, roCompat uint32) []byte {
+	image := make([]byte, 4096)
+	sb := image[1024:]
+	binary.LittleEndian.PutUint32(sb[0x18:], 2) // 4096
+	binary.LittleEndian.PutUint16(sb[0x38:], extMagic)
+	binary.LittleEndian.PutUint32(sb[0x5c:], compat)
+	binary.LittleEndian.PutUint32(sb[0x60:], incompat)
+	binary.LittleEndian.PutUint32(sb[0x64:], roCompat)
+	copy(sb[0x68:], rawUUID())
+	copy(sb[0x78:], "rootfs")
+	return image
+}
+
+func fatImage(fat32 bool, label string) []byte {
+	image := make([]byte, 4096)
+	image[0], image[1], image[2] = 0xeb, 0x3c, 0x90
+	binary.LittleEndian.PutUint16(image[11:], 512)
+	image[13] = 4
+	ebpb, fsType := image[36:], "FAT16   "
+	if fat32 {
+		ebpb, fsType = image[64:], "FAT32   "
+	}
+	ebpb[2] = 0x29
+	binary.LittleEndian.PutUint32(ebpb[3:], 0x3312932f)
+	copy(ebpb[7:18], label)
+	copy(ebpb[18:26], fsType)
+	image[510], image[511] = 0x55, 0xaa
+	return image
+}
+
+func exfatImage() []byte {
+	image := make([]byte, 64*1024)
+	copy(image[3:], "EXFAT   ")
+	binary.LittleEndian.PutUint32(image[88:], 32) // cluster heap at sector 32
+	binary.LittleEndian.PutUint32(image[96:], 4)  // root directory in cluster 4
+	binary.LittleEndian.PutUint32(image[100:], 0x9e5b2a1c)
+	image[108], image[109] = 9, 3 // 512 bytes per sector, 8 sectors per cluster
+	image[510], image[511] = 0x55, 0xaa
+	root := image[32*512+2*8*512:]
+	root[0] = 0x81 // allocation bitmap
+	root[32], root[33] = exfatEntryLabel, 6
+	putUTF16(root[34:], "Photos")
+	return image
+}
+
+func btrfsImage() []byte {
+	image := make([]byte, btrfsSuperblock+0x1000)
+	sb := image[btrfsSuperblock:]
+	copy(sb[0x20:], rawUUID())
+	copy(sb[0x40:], "_BHRfS_M")
+	binary.LittleEndian.PutUint32(sb[0x90:], 4096)
+	copy(sb[0x12b:], "data")
+	return image
+}
+
+func swapImage(pageSize int) []byte {
+	image := make([]byte, pageSize)
+	binary.LittleEndian.PutUint32(image[1024:], 1)
+	copy(image[1036:], rawUUID())
+	copy(image[1052:], "swap0")
+	copy(image[pageSize-10:], "SWAPSPACE2")
+	return image
+}
+
+func TestProbe(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		image    []byte
+		expected Filesystem
+	}{
+		{"ext2", extImage(0, 0x2, 0x1), Filesystem{Type: TypeExt2, Uuid: testUUID, Label: "rootfs", BlockSize: 4096}},
+		{"ext3", extImage(extCompatHasJournal, 0x2, 0x1), Filesystem{Type: TypeExt3, SecType: TypeExt2, Uuid: testUUID, Label: "rootfs", BlockSize: 4096}},
+		{"ext4", extImage(extCompatHasJournal, 0x2|0x40|0x200, 0x1), Filesystem{Type: TypeExt4, Uuid: testUUID, Label: "rootfs", BlockSize: 4096}},
+		{"fat16", fatImage(false, "boot       "), Filesystem{Type: TypeVfat, SecType: "msdos", Version: "FAT16", Uuid: "3312-932F", Label: "boot", BlockSize: 512}},
+		{"fat32", fatImage(true, "NO NAME    "), Filesystem{Type: TypeVfat, Version: "FAT32", Uuid: "3312-932F", BlockSize: 512}},
+		{"exfat", exfatImage(), Filesystem{Type: TypeExfat, Uuid: "9E5B-2A1C", Label: "Photos", BlockSize: 512}},
+		{"btrfs", btrfsImage(), Filesystem{Type: TypeBtrfs, Uuid: testUUID, Label: "data", BlockSize: 4096}},
+		{"f2fs", f2fsImage(), Filesystem{Type: TypeF2fs, Uuid: testUUID, Label: "flash", BlockSize: 4096}},
+		{"xfs", xfsImage(), Filesystem{Type: TypeXfs, Uuid: testUUID, Label: "media", BlockSize: 4096}},
+		{"swap", swapImage(4096), Filesystem{Type: TypeSwap, Version: "1", Uuid: testUUID, Label: "swap0", BlockSize: 4096}},
+		{"swap16k", swapImage(16384), Filesystem{Type: TypeSwap, Version: "1", Uuid: testUUID, Label: "swap0", BlockSize: 16384}},
+		{"luks1", luksImage(1), Filesystem{Type: TypeLUKS, Version: "1", Uuid: testUUID}},
+		{"luks2", luksImage(2), Filesystem{Type: TypeLUKS, Version: "2", Uuid: testUUID, Label: "cryptroot"}},
+	}
+
+	for _, test := range tests {
+		t.Logf("Probing %s\n", test.name)
+		fs, err := Probe(bytes.NewReader(test.image))
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.expected, *fs)
+		}
+	}
+
+	_, err := Probe(bytes.NewReader(make([]byte, 128*1024)))
+	assert.Equal(t, ErrUnknownFilesystem, err)
+	_, err = Probe(bytes.NewReader(nil))
+	assert.Equal(t, ErrUnknownFilesystem, err)
+}
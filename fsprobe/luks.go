@@ -0,0 +1,31 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const luksMagic = "LUKS\xba\xbe"
+
+// probeLUKS - LUKS1 and LUKS2, only LUKS2 has a label
+func probeLUKS(r io.ReaderAt) *Filesystem {
+	hdr := read(r, 0, 512)
+	if hdr == nil || string(hdr[0:6]) != luksMagic {
+		return nil
+	}
+	version := binary.BigEndian.Uint16(hdr[6:])
+	fs := &Filesystem{Type: TypeLUKS, Version: fmt.Sprintf("%d", version), Uuid: cstring(hdr[168:208])}
+	if version == 2 {
+		fs.Label = cstring(hdr[24:72])
+	}
+	return fs
+}
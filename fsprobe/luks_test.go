@@ -0,0 +1,43 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func luksImage(version uint16) []byte {
+	image := make([]byte, 4096)
+	copy(image, luksMagic)
+	binary.BigEndian.PutUint16(image[6:], version)
+	copy(image[24:], "cryptroot")
+	copy(image[168:], testUUID)
+	return image
+}
+
+func TestProbeLUKS(t *testing.T) {
+
+	fs := probeLUKS(bytes.NewReader(luksImage(1)))
+	if assert.NotNil(t, fs) {
+		assert.Equal(t, Filesystem{Type: TypeLUKS, Version: "1", Uuid: testUUID}, *fs)
+	}
+	fs = probeLUKS(bytes.NewReader(luksImage(2)))
+	if assert.NotNil(t, fs) {
+		assert.Equal(t, Filesystem{Type: TypeLUKS, Version: "2", Uuid: testUUID, Label: "cryptroot"}, *fs)
+	}
+
+	image := luksImage(2)
+	image[5] = 0
+	assert.Nil(t, probeLUKS(bytes.NewReader(image)))
+	assert.Nil(t, probeLUKS(bytes.NewReader(image[:256])))
+}
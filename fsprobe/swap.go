@@ -0,0 +1,32 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"io"
+)
+
+// swapPageSizes - the swap signature is at the end of the first page. The Raspberry Pi 5 kernel uses 16K pages
+var swapPageSizes = []int64{4096, 8192, 16384, 65536}
+
+// probeSwap - swap version 1 created by mkswap
+func probeSwap(r io.ReaderAt) *Filesystem {
+	for _, pageSize := range swapPageSizes {
+		signature := read(r, pageSize-10, 10)
+		if signature == nil || string(signature) != "SWAPSPACE2" {
+			continue
+		}
+		fs := &Filesystem{Type: TypeSwap, Version: "1", BlockSize: int(pageSize)}
+		if header := read(r, 1024, 44); header != nil {
+			fs.Uuid, fs.Label = uuid(header[12:28]), cstring(header[28:44])
+		}
+		return fs
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// probeXfs - superblock is big endian
+func probeXfs(r io.ReaderAt) *Filesystem {
+	sb := read(r, 0, 512)
+	if sb == nil || string(sb[0:4]) != "XFSB" {
+		return nil
+	}
+	return &Filesystem{Type: TypeXfs, Uuid: uuid(sb[32:48]), Label: cstring(sb[108:120]),
+		BlockSize: int(binary.BigEndian.Uint32(sb[4:]))}
+}
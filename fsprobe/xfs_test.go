@@ -0,0 +1,39 @@
+package fsprobe
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func xfsImage() []byte {
+	image := make([]byte, 512)
+	copy(image, "XFSB")
+	binary.BigEndian.PutUint32(image[4:], 4096)
+	copy(image[32:], rawUUID())
+	copy(image[108:], "media")
+	return image
+}
+
+func TestProbeXfs(t *testing.T) {
+
+	fs := probeXfs(bytes.NewReader(xfsImage()))
+	if assert.NotNil(t, fs) {
+		assert.Equal(t, Filesystem{Type: TypeXfs, Uuid: testUUID, Label: "media", BlockSize: 4096}, *fs)
+	}
+
+	image := xfsImage()
+	copy(image, "XFSC")
+	assert.Nil(t, probeXfs(bytes.NewReader(image)))
+	assert.Nil(t, probeXfs(bytes.NewReader(image[:256])))
+}
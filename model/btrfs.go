@@ -0,0 +1,210 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// Subvolume - btrfs subvolume
+type Subvolume struct {
+	ID          int
+	Path        string // @home
+	Uuid        string
+	ParentUuid  string // uuid of the snapshot source
+	Default     bool
+	Mountpoints []string
+}
+
+func (s Subvolume) String() string {
+	return fmt.Sprintf("Subvolume: %s - ID: %d - Uuid: %s - ParentUuid: %s - Default: %t - Mountpoints: %s",
+		s.Path, s.ID, s.Uuid, s.ParentUuid, s.Default, strings.Join(s.Mountpoints, ","))
+}
+
+// IsSnapshot -
+func (s Subvolume) IsSnapshot() bool {
+	return s.ParentUuid != ""
+}
+
+// BtrfsFilesystem - btrfs filesystem with its subvolumes
+type BtrfsFilesystem struct {
+	Device     string // /dev/mmcblk0p2
+	Uuid       string
+	Label      string
+	DefaultID  int
+	Subvolumes []*Subvolume
+}
+
+func (f BtrfsFilesystem) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("BtrfsFilesystem: %s - Uuid: %s - Label: %s - DefaultID: %d\n", f.Device, f.Uuid, f.Label, f.DefaultID))
+	for _, s := range f.Subvolumes {
+		result.WriteString(s.String())
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+// Subvolume - subvolume with id, or nil
+func (f BtrfsFilesystem) Subvolume(id int) *Subvolume {
+	for _, s := range f.Subvolumes {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// mountSubvolume - id and path of the subvolume mounted, taken from the subvolid and subvol options
+func mountSubvolume(m *sysfs.Mount) (int, string) {
+	id, path := commands.BtrfsTopLevel, strings.TrimPrefix(m.Root, "/")
+	for _, option := range strings.Split(m.SuperOptions, ",") {
+		switch {
+		case strings.HasPrefix(option, "subvolid="):
+			id, _ = strconv.Atoi(strings.TrimPrefix(option, "subvolid="))
+		case strings.HasPrefix(option, "subvol="):
+			path = strings.TrimPrefix(strings.TrimPrefix(option, "subvol="), "/")
+		}
+	}
+	return id, path
+}
+
+// btrfsMounts - mounts of btrfs filesystems by device. Bind mounts are skipped
+func btrfsMounts(mountTable *sysfs.MountTable, devices *sysfs.Devices) (map[string][]*sysfs.Mount, []string) {
+	mounts := make(map[string][]*sysfs.Mount)
+	var order []string
+	for _, m := range mountTable.Mounts {
+		if m.FSType != "btrfs" || m.Bind {
+			continue
+		}
+		device := mountTable.Device(m, devices)
+		if device == "" {
+			device = m.Source
+		}
+		if _, ok := mounts[device]; !ok {
+			order = append(order, device)
+		}
+		mounts[device] = append(mounts[device], m)
+	}
+	return mounts, order
+}
+
+// addMountpoints - adds the mountpoints of the mounts to their subvolumes. Subvolumes which are mounted but unknown
+// are added
+func addMountpoints(fs *BtrfsFilesystem, mounts []*sysfs.Mount) {
+	for _, m := range mounts {
+		id, path := mountSubvolume(m)
+		s := fs.Subvolume(id)
+		if s == nil {
+			s = &Subvolume{ID: id, Path: path, Default: id == fs.DefaultID}
+			fs.Subvolumes = append(fs.Subvolumes, s)
+		}
+		s.Mountpoints = append(s.Mountpoints, m.Mountpoint)
+	}
+}
+
+// newBtrfsFilesystems - btrfs filesystems of the mount table with all subvolumes reported by btrfs. Without btrfs
+// only the mounted subvolumes are available
+func newBtrfsFilesystems(ctx context.Context, mountTable *sysfs.MountTable, devices *sysfs.Devices) []*BtrfsFilesystem {
+
+	mounts, order := btrfsMounts(mountTable, devices)
+
+	var result []*BtrfsFilesystem
+	for _, device := range order {
+		fs := &BtrfsFilesystem{Device: device, DefaultID: commands.BtrfsTopLevel}
+
+		btrfs, err := commands.NewBtrfs(ctx, mounts[device][0].Mountpoint)
+		switch {
+		case err == nil:
+			fs.DefaultID = btrfs.DefaultID
+			for _, s := range btrfs.Subvolumes {
+				fs.Subvolumes = append(fs.Subvolumes, &Subvolume{ID: s.ID, Path: s.Path, Uuid: s.Uuid, ParentUuid: s.ParentUuid,
+					Default: s.ID == btrfs.DefaultID})
+			}
+		case commands.IsNotFound(err):
+			tools.Logger.Debugf("btrfs not installed, subvolumes of %s not available", device)
+		default:
+			tools.Logger.Warnf("Subvolumes of %s not available: %s", device, err.Error())
+		}
+
+		addMountpoints(fs, mounts[device])
+		result = append(result, fs)
+	}
+	return result
+}
+
+// newBtrfsFilesystemsFromMounts - btrfs filesystems of the mount table with their mounted subvolumes. Listing all
+// subvolumes requires root
+func newBtrfsFilesystemsFromMounts(mountTable *sysfs.MountTable, devices *sysfs.Devices) []*BtrfsFilesystem {
+
+	mounts, order := btrfsMounts(mountTable, devices)
+
+	var result []*BtrfsFilesystem
+	for _, device := range order {
+		fs := &BtrfsFilesystem{Device: device}
+		addMountpoints(fs, mounts[device])
+		result = append(result, fs)
+	}
+	return result
+}
+
+// linkBtrfsFilesystems - sets uuid and label of the filesystems from their partitions
+func linkBtrfsFilesystems(disks []*Disk, filesystems []*BtrfsFilesystem) {
+	for _, f := range filesystems {
+		for _, d := range disks {
+			for _, p := range d.Partitions {
+				if p.Name == f.Device {
+					f.Uuid, f.Label = p.Uuid, p.Label
+				}
+			}
+		}
+	}
+}
+
+// btrfsProvider - btrfs filesystems of the mount table. All subvolumes are listed with btrfs, otherwise only the mounted
+// subvolumes are available
+type btrfsProvider struct {
+	provider
+	subvolumes  bool
+	filesystems []*BtrfsFilesystem
+}
+
+// Collect - requires the mount table and the devices of stacked devices, e.g. raid arrays
+func (p *btrfsProvider) Collect(ctx context.Context, d *Discovery) error {
+	if d.MountTable == nil {
+		return nil
+	}
+	if p.subvolumes {
+		p.filesystems = newBtrfsFilesystems(ctx, d.MountTable, d.Devices)
+	} else {
+		p.filesystems = newBtrfsFilesystemsFromMounts(d.MountTable, d.Devices)
+	}
+	return ctx.Err()
+}
+
+// Contribute -
+func (p *btrfsProvider) Contribute(d *Discovery) {
+	d.System.Btrfs = p.filesystems
+}
+
+func (p *btrfsProvider) String() string {
+	var result bytes.Buffer
+	for _, f := range p.filesystems {
+		result.WriteString(f.String())
+	}
+	return result.String()
+}
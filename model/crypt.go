@@ -0,0 +1,183 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/fsprobe"
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// CryptDevice - open dm-crypt mapping of a LUKS or plain container
+type CryptDevice struct {
+	Name      string // cryptroot
+	Device    string // /dev/mapper/cryptroot
+	Container string // partition or disk the mapping is stacked on, e.g. /dev/mmcblk0p2
+
+	// from cryptsetup status
+	Type    string // LUKS1, LUKS2 or PLAIN
+	Cipher  string
+	KeySize int
+	Offset  int64 // 512 byte sectors of the LUKS header
+
+	// from lsblk or the superblock
+	FileSystem string
+	Uuid       string
+	Label      string
+	Mountpoint string
+}
+
+func (c CryptDevice) String() string {
+	return fmt.Sprintf("CryptDevice: %s - Device: %s - Container: %s - Type: %s - Cipher: %s - KeySize: %d - Offset: %d - FileSystem: %s - Uuid: %s - Label: %s - Mountpoint: %s",
+		c.Name, c.Device, c.Container, c.Type, c.Cipher, c.KeySize, c.Offset, c.FileSystem, c.Uuid, c.Label, c.Mountpoint)
+}
+
+// IsLuks -
+func (c CryptDevice) IsLuks() bool {
+	return c.Type == "LUKS1" || c.Type == "LUKS2"
+}
+
+// newCryptDevices - crypt holders reported by lsblk with the details of cryptsetup status. Without cryptsetup only the
+// lsblk attributes are available
+func newCryptDevices(ctx context.Context, holders map[string]*commands.LsblkDevice, parents map[string]string) []*CryptDevice {
+
+	var result []*CryptDevice
+	for path, h := range holders {
+		if h.Type != "crypt" {
+			continue
+		}
+		crypt := &CryptDevice{Name: h.Name, Device: path, Container: parents[path], FileSystem: h.Fstype, Uuid: h.Uuid, Label: h.Label}
+		if len(h.Mountpoints) > 0 {
+			crypt.Mountpoint = h.Mountpoints[0]
+		}
+
+		status, err := commands.NewCryptStatus(ctx, h.Name)
+		switch {
+		case err == nil:
+			crypt.Type, crypt.Cipher, crypt.KeySize, crypt.Offset = status.Type, status.Cipher, status.KeySize, status.Offset
+			if crypt.Container == "" {
+				crypt.Container = status.Device
+			}
+		case commands.IsNotFound(err):
+			tools.Logger.Debugf("cryptsetup not installed, status of %s not available", h.Name)
+		default:
+			tools.Logger.Warnf("Status of %s not available: %s", h.Name, err.Error())
+		}
+		result = append(result, crypt)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// newCryptDevicesFromSysfs - dm-crypt mappings of the device mapper. The filesystem is read from the superblock of the
+// mapping if it's readable. Mappings are added to devices to resolve mount sources on encrypted filesystems
+func newCryptDevicesFromSysfs(reader *sysfs.Reader, mappers []*sysfs.DeviceMapper, mountTable *sysfs.MountTable, devices *sysfs.Devices) []*CryptDevice {
+
+	var result []*CryptDevice
+	for _, m := range mappers {
+		if m.CryptType() == "" {
+			continue
+		}
+		crypt := &CryptDevice{Name: m.Mapper, Device: "/dev/mapper/" + m.Mapper, Type: m.CryptType()}
+		if len(m.Slaves) == 1 {
+			crypt.Container = "/dev/" + m.Slaves[0]
+		}
+		if fs, err := fsprobe.ProbeFile(filepath.Join(reader.Root, "dev", m.Name)); err == nil {
+			crypt.FileSystem, crypt.Uuid, crypt.Label = fs.Type, fs.Uuid, fs.Label
+		}
+		if mount := mountTable.FindDev(m.Dev); mount != nil {
+			crypt.Mountpoint = mount.Mountpoint
+		}
+
+		devices.Add(crypt.Device, m.Dev, "", crypt.Uuid, crypt.Label)
+		if crypt.Container != "" {
+			devices.AddParent(crypt.Device, crypt.Container)
+		}
+		result = append(result, crypt)
+	}
+	return result
+}
+
+// linkCryptDevices - sets the mapper of the container partitions
+func linkCryptDevices(disks []*Disk, cryptDevices []*CryptDevice) {
+	for _, c := range cryptDevices {
+		for _, d := range disks {
+			for _, p := range d.Partitions {
+				if p.Name == c.Container {
+					p.Mapper = c.Device
+				}
+			}
+		}
+	}
+}
+
+// cryptsetupProvider - crypt holders reported by lsblk with their cryptsetup status
+type cryptsetupProvider struct {
+	provider
+	devices []*CryptDevice
+}
+
+// Collect -
+func (p *cryptsetupProvider) Collect(ctx context.Context, d *Discovery) error {
+	p.devices = newCryptDevices(ctx, d.Holders, d.Devices.Parents)
+	return ctx.Err()
+}
+
+// Contribute -
+func (p *cryptsetupProvider) Contribute(d *Discovery) {
+	d.System.CryptDevices = p.devices
+}
+
+func (p *cryptsetupProvider) String() string {
+	var result bytes.Buffer
+	for _, c := range p.devices {
+		result.WriteString(c.String())
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+// dmProvider - dm-crypt mappings of the device mapper in sysfs
+type dmProvider struct {
+	provider
+	reader  *sysfs.Reader
+	mappers []*sysfs.DeviceMapper
+}
+
+// Collect -
+func (p *dmProvider) Collect(ctx context.Context, d *Discovery) (err error) {
+	p.mappers, err = p.reader.DeviceMappers()
+	return err
+}
+
+// Contribute - mappings are added to the devices and therefore created sequentially
+func (p *dmProvider) Contribute(d *Discovery) {
+	if d.MountTable != nil {
+		d.System.CryptDevices = newCryptDevicesFromSysfs(p.reader, p.mappers, d.MountTable, d.Devices)
+	}
+}
+
+func (p *dmProvider) String() string {
+	var result bytes.Buffer
+	for _, m := range p.mappers {
+		result.WriteString(m.String())
+		result.WriteString("\n")
+	}
+	return result.String()
+}
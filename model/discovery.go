@@ -0,0 +1,323 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// Priorities of the disk and partition attributes contributed by providers. Values of providers with a higher priority
+// override conflicting values of providers with a lower priority
+const (
+	PriorityInventory      = 10 // lsblk and sysfs list all block devices
+	PriorityPartitionTable = 20 // parted and the partition table read directly
+	PriorityProbe          = 30 // blkid
+	PrioritySuperblock     = 40 // superblocks read directly
+)
+
+// Provider - source of facts about the system, e.g. a tool like lsblk or the sysfs of the kernel
+type Provider interface {
+	// Name - e.g. lsblk, used for errors and timings
+	Name() string
+	// Priority - priority of the disk and partition attributes contributed
+	Priority() int
+	// Stage - providers of a stage collect after all providers of the previous stages contributed, e.g. parted
+	// requires the disks contributed by lsblk
+	Stage() int
+	// Collect - retrieves the raw data. Providers of a stage collect concurrently and must only write their own data
+	Collect(ctx context.Context, d *Discovery) error
+	// Contribute - adds the collected facts to the discovery. Providers of a stage contribute in the order they
+	// were passed to Discover. A provider contributes the data collected successfully even if Collect failed
+	Contribute(d *Discovery)
+	// String - raw data collected
+	String() string
+}
+
+// provider - name, priority and stage of a provider
+type provider struct {
+	name     string
+	priority int
+	stage    int
+}
+
+// Name -
+func (p provider) Name() string {
+	return p.name
+}
+
+// Priority -
+func (p provider) Priority() int {
+	return p.priority
+}
+
+// Stage -
+func (p provider) Stage() int {
+	return p.stage
+}
+
+// fact - value of a Disk or Partition field contributed by a provider
+type fact struct {
+	value    reflect.Value
+	provider string
+	priority int
+}
+
+// facts - facts by field name
+type facts map[string][]fact
+
+// add - adds all fields of v which are neither zero nor N/A. Skipped fields are linked later, e.g. Partitions
+func (f facts) add(p Provider, v interface{}, skip ...string) {
+	value := reflect.ValueOf(v)
+	for i := 0; i < value.NumField(); i++ {
+		field, name := value.Field(i), value.Type().Field(i).Name
+		if field.IsZero() || field.Kind() == reflect.String && field.String() == "N/A" || contains(skip, name) {
+			continue
+		}
+		f[name] = append(f[name], fact{value: field, provider: p.Name(), priority: p.Priority()})
+	}
+}
+
+// value - fact with the highest priority, the first contributed if priorities are equal
+func (f facts) value(name string) fact {
+	result := f[name][0]
+	for _, candidate := range f[name][1:] {
+		if candidate.priority > result.priority {
+			result = candidate
+		}
+	}
+	return result
+}
+
+// apply - sets the fields of the struct v points to
+func (f facts) apply(v interface{}) {
+	target := reflect.ValueOf(v).Elem()
+	for name := range f {
+		target.FieldByName(name).Set(f.value(name).value)
+	}
+}
+
+// conflicts - fields with different values contributed by different providers
+func (f facts) conflicts() []string {
+	var result []string
+	for name := range f {
+		result = append(result, f.conflict(name)...)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// conflict - values of field name which differ from the value with the highest priority
+func (f facts) conflict(name string) []string {
+	var result []string
+	winner := f.value(name)
+	for _, candidate := range f[name] {
+		if !equal(name, candidate.value, winner.value) {
+			result = append(result, fmt.Sprintf("%s is %v from %s but %v from %s", name, winner.value, winner.provider,
+				candidate.value, candidate.provider))
+		}
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// diskFacts - facts of a disk and its partitions by partition number
+type diskFacts struct {
+	disk       facts
+	partitions map[int]facts
+}
+
+// Discovery - discovers the system with providers. Providers contribute disk and partition attributes as facts and
+// may share data with providers of later stages, e.g. the mount table
+type Discovery struct {
+	Providers []Provider
+	Timings   *commands.Timings
+	System    *System // nil if no disk was discovered
+
+	// shared by providers
+	MountTable *sysfs.MountTable
+	Devices    *sysfs.Devices                   // block devices to resolve mount sources
+	Holders    map[string]*commands.LsblkDevice // devices stacked on disks and partitions, reported by lsblk only
+
+	workers int
+	disks   map[string]*diskFacts // by disk name, e.g. sda
+	removed map[string]bool
+}
+
+// diskName - sda for /dev/sda
+func diskName(name string) string {
+	return strings.TrimPrefix(name, "/dev/")
+}
+
+func (d *Discovery) diskFacts(name string) *diskFacts {
+	name = diskName(name)
+	if _, ok := d.disks[name]; !ok {
+		d.disks[name] = &diskFacts{disk: facts{}, partitions: make(map[int]facts)}
+	}
+	return d.disks[name]
+}
+
+// AddDisk - contributes the attributes of disk name, e.g. sda. Partitions are ignored
+func (d *Discovery) AddDisk(p Provider, name string, disk Disk) {
+	d.diskFacts(name).disk.add(p, disk, "Partitions")
+}
+
+// AddPartition - contributes the attributes of a partition of disk name. Partitions are identified by their number
+func (d *Discovery) AddPartition(p Provider, name string, partition Partition) {
+	partitions := d.diskFacts(name).partitions
+	if _, ok := partitions[partition.Number]; !ok {
+		partitions[partition.Number] = facts{}
+	}
+	partitions[partition.Number].add(p, partition, "Mapper", "RaidArray")
+}
+
+// RemoveDisk - removes a disk contributed by another provider, e.g. a card reader without medium
+func (d *Discovery) RemoveDisk(name string) {
+	d.removed[diskName(name)] = true
+}
+
+// Disks - names of all disks contributed so far, e.g. sda
+func (d *Discovery) Disks() []string {
+	result := make([]string, 0, len(d.disks))
+	for name := range d.disks {
+		if !d.removed[name] {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Disk - disk name with all partitions as contributed so far. The name is used if no provider contributed one. Sources
+// lists the provider of each attribute
+func (d *Discovery) Disk(name string) *Disk {
+	facts := d.diskFacts(name)
+	disk := &Disk{Name: diskName(name)}
+	facts.disk.apply(disk)
+	disk.Sources = facts.disk.sources()
+	disk.Partitions = make(map[int]*Partition, len(facts.partitions))
+	for number, f := range facts.partitions {
+		partition := &Partition{}
+		f.apply(partition)
+		partition.Sources = f.sources()
+		if partition.Name == "" {
+			partition.Name = commands.PartitionName("/dev/"+diskName(name), number)
+		}
+		disk.Partitions[number] = partition
+	}
+	return disk
+}
+
+// Discover - discovers the system stage by stage. The providers of a stage collect concurrently with at most
+// commands.MaxWorkers workers if parallelExecution is set. Failures of providers or single disks are returned as
+// commands.DiscoveryErrors together with the partially discovered system. The system is nil if no disks were
+// discovered
+func Discover(ctx context.Context, providers []Provider, parallelExecution bool) (*Discovery, error) {
+
+	d := &Discovery{Providers: providers, Timings: &commands.Timings{}, Devices: sysfs.NewDevices(),
+		Holders: make(map[string]*commands.LsblkDevice), System: &System{}, workers: commands.Workers(parallelExecution),
+		disks: make(map[string]*diskFacts), removed: make(map[string]bool)}
+	start := time.Now()
+
+	stages := make(map[int][]Provider)
+	var order []int
+	for _, p := range providers {
+		if _, ok := stages[p.Stage()]; !ok {
+			order = append(order, p.Stage())
+		}
+		stages[p.Stage()] = append(stages[p.Stage()], p)
+	}
+	sort.Ints(order)
+
+	var errs commands.DiscoveryErrors
+	for _, stage := range order {
+		stageProviders := stages[stage]
+		stageErrs := make([]error, len(stageProviders))
+		commands.ForEach(ctx, len(stageProviders), d.workers, func(i int) {
+			defer d.Timings.Measure(stageProviders[i].Name(), time.Now())
+			stageErrs[i] = stageProviders[i].Collect(ctx, d)
+		})
+		for i, p := range stageProviders {
+			if failures := commands.AsDiscoveryErrors(stageErrs[i]); failures != nil {
+				errs = append(errs, failures...)
+			} else {
+				errs.Add(p.Name(), "", stageErrs[i])
+			}
+			p.Contribute(d)
+		}
+	}
+
+	if names := d.Disks(); len(names) > 0 {
+		d.assemble(names)
+	} else {
+		d.System = nil
+	}
+	d.Timings.Measure("total", start)
+
+	for _, e := range errs {
+		tools.Logger.Errorf("Discover: %s", e.Error())
+	}
+	tools.Logger.Debugf("Discover: Timings with %d workers\n%s", d.workers, d.Timings)
+	return d, errs.Err()
+}
+
+// assemble - creates the disks from their facts and links the stacked devices contributed by the providers
+func (d *Discovery) assemble(names []string) {
+
+	for _, name := range names {
+		disk := d.Disk(name)
+		disk.Warnings = d.disks[name].reconcile(disk)
+		for _, warning := range disk.Warnings {
+			tools.Logger.Warnf("Discover: %s", warning)
+		}
+		for _, partition := range disk.Partitions {
+			// mounts may reference partitions by PARTUUID, UUID or LABEL
+			d.Devices.Add(partition.Name, "", partition.Partuuid, partition.Uuid, partition.Label)
+		}
+		d.System.Disks = append(d.System.Disks, disk)
+	}
+
+	linkCryptDevices(d.System.Disks, d.System.CryptDevices)
+	linkRaidArrays(d.System.Disks, d.System.RaidArrays)
+	linkBtrfsFilesystems(d.System.Disks, d.System.Btrfs)
+
+	if d.MountTable != nil {
+		systemDevices := commands.NewSystemDevicesFromMountTable(d.MountTable, d.Devices)
+		d.System.Bootpartition, d.System.Rootpartition = systemDevices.Bootdevice, systemDevices.Rootdevice
+	}
+	d.System.Timings = d.Timings
+}
+
+// String - raw data collected by all providers
+func (d Discovery) String() string {
+	var result bytes.Buffer
+	sep := strings.Repeat("*", 30)
+	for _, p := range d.Providers {
+		result.WriteString(fmt.Sprintf("%s*** %s ***%s\n", sep, p.Name(), sep))
+		result.WriteString(p.String())
+	}
+	return result.String()
+}
@@ -0,0 +1,103 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+// noRunner - fails all commands
+type noRunner struct{}
+
+func (noRunner) Run(c *commands.Cmd) (*commands.Invocation, error) {
+	return nil, fmt.Errorf("unexpected command %s", c)
+}
+
+func TestDiscover(t *testing.T) {
+
+	defer replay(t, "raid")()
+	replayer := commands.CurrentRunner()
+
+	for _, parallel := range []bool{false, true} {
+		discovery, err := Discover(context.Background(), CommandProviders(), parallel)
+		assert.NoError(t, err)
+		assert.Len(t, discovery.System.Disks, 3)
+		assert.Len(t, discovery.System.RaidArrays, 1)
+		assert.Contains(t, discovery.Timings.String(), "parted /dev/sdb ")
+
+		// the raw data is collected once
+		commands.SetRunner(noRunner{})
+		collected := discovery.String()
+		commands.SetRunner(replayer)
+		assert.Contains(t, collected, "*** parted ***")
+		assert.Contains(t, collected, "Disk: /dev/sdb ")
+		assert.Contains(t, collected, "*** mdadm ***")
+	}
+}
+
+// factProvider - contributes fixed attributes of disk sda
+type factProvider struct {
+	provider
+	disk       Disk
+	partitions []Partition
+}
+
+func (p *factProvider) Collect(ctx context.Context, d *Discovery) error {
+	return nil
+}
+
+func (p *factProvider) Contribute(d *Discovery) {
+	d.AddDisk(p, "sda", p.disk)
+	for _, partition := range p.partitions {
+		d.AddPartition(p, "sda", partition)
+	}
+}
+
+func (p *factProvider) String() string {
+	return p.name + "\n"
+}
+
+func TestDiscoverPriorities(t *testing.T) {
+
+	defer replay(t, "raid")()
+
+	providers := []Provider{
+		&factProvider{provider: provider{"low", 10, 0}, disk: Disk{Model: "Elements", Size: "1000B"},
+			partitions: []Partition{{Number: 1, Type: "ext4", Label: "N/A", Uuid: "1234"}, {Number: 2, Type: "swap"}}},
+		&factProvider{provider: provider{"high", 30, 1}, disk: Disk{Model: "WD Elements"},
+			partitions: []Partition{{Number: 1, Type: "vfat", Label: "boot"}}},
+		&factProvider{provider: provider{"equal", 30, 1}, partitions: []Partition{{Number: 1, Type: "btrfs"}}},
+	}
+	discovery, err := Discover(context.Background(), providers, true)
+	assert.NoError(t, err)
+	if assert.Len(t, discovery.System.Disks, 1) {
+		disk := discovery.System.Disks[0]
+		assert.Equal(t, "sda", disk.Name)
+		assert.Equal(t, "WD Elements", disk.Model)
+		assert.Equal(t, "1000B", disk.Size)
+		assert.Equal(t, "/dev/sda1", disk.Partitions[1].Name)
+		assert.Equal(t, "vfat", disk.Partitions[1].Type)
+		assert.Equal(t, "boot", disk.Partitions[1].Label)
+		assert.Equal(t, "1234", disk.Partitions[1].Uuid)
+		assert.Equal(t, "swap", disk.Partitions[2].Type)
+	}
+	assert.Equal(t, []string{"Type is vfat from high but btrfs from equal", "Type is vfat from high but ext4 from low"},
+		discovery.disks["sda"].partitions[1].conflicts())
+	assert.Contains(t, discovery.String(), "*** low ***"+strings.Repeat("*", 30)+"\nlow\n")
+
+	discovery, err = Discover(context.Background(), nil, false)
+	assert.NoError(t, err)
+	assert.Nil(t, discovery.System)
+}
@@ -0,0 +1,69 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/fsprobe"
+)
+
+// applyFilesystem - sets the filesystem attributes read from the superblock
+func applyFilesystem(partition *Partition, fs *fsprobe.Filesystem) {
+	partition.Type, partition.FileSystem, partition.Uuid, partition.Label = fs.Type, fs.Type, fs.Uuid, fs.Label
+}
+
+// superblockProvider - filesystems read from the superblocks of all partitions contributed before. Partitions which
+// are not readable are skipped. Filesystem attributes of other providers which differ are reported when the system is
+// reconciled
+type superblockProvider struct {
+	provider
+	root   string // root of the dev directory
+	probed map[string]map[int]*fsprobe.Filesystem
+}
+
+// Collect -
+func (p *superblockProvider) Collect(ctx context.Context, d *Discovery) error {
+	p.probed = make(map[string]map[int]*fsprobe.Filesystem)
+	for _, name := range d.Disks() {
+		p.probed[name] = make(map[int]*fsprobe.Filesystem)
+		for number, partition := range d.Disk(name).Partitions {
+			if fs, err := fsprobe.ProbeFile(filepath.Join(p.root, "dev", diskName(partition.Name))); err == nil {
+				p.probed[name][number] = fs
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// Contribute -
+func (p *superblockProvider) Contribute(d *Discovery) {
+	for name, filesystems := range p.probed {
+		for number, fs := range filesystems {
+			partition := Partition{Number: number}
+			applyFilesystem(&partition, fs)
+			d.AddPartition(p, name, partition)
+		}
+	}
+}
+
+func (p *superblockProvider) String() string {
+	var lines []string
+	for name, filesystems := range p.probed {
+		for number, fs := range filesystems {
+			lines = append(lines, fmt.Sprintf("%s - Number: %d - Type: %s - Uuid: %s - Label: %s\n", name, number, fs.Type, fs.Uuid, fs.Label))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,129 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+)
+
+// LogicalVolume -
+type LogicalVolume struct {
+
+	// from lvs
+	Name       string // home
+	Device     string // /dev/data/home
+	MapperName string // data-home
+	Size       int64
+	Attributes string // -wi-ao----
+	Origin     string // origin of a snapshot
+	Pool       string // thin pool of a thin volume
+	LvUuid     string
+
+	// from lsblk
+	FileSystem string
+	Uuid       string
+	Label      string
+	Mountpoint string
+}
+
+func (l LogicalVolume) String() string {
+	return fmt.Sprintf("LogicalVolume: %s - Device: %s - Size: %d - Attributes: %s - Origin: %s - FileSystem: %s - Uuid: %s - Label: %s - Mountpoint: %s",
+		l.Name, l.Device, l.Size, l.Attributes, l.Origin, l.FileSystem, l.Uuid, l.Label, l.Mountpoint)
+}
+
+// IsSnapshot -
+func (l LogicalVolume) IsSnapshot() bool {
+	return strings.HasPrefix(l.Attributes, "s") || l.Origin != ""
+}
+
+// VolumeGroup -
+type VolumeGroup struct {
+	Name            string
+	Size            int64
+	Free            int64
+	ExtentSize      int64
+	Uuid            string
+	PhysicalVolumes []string // /dev/sda1 or /dev/sdc if the whole disk is used
+	LogicalVolumes  []*LogicalVolume
+}
+
+func (g VolumeGroup) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("VolumeGroup: %s - Size: %d - Free: %d - ExtentSize: %d - PhysicalVolumes: %s\n",
+		g.Name, g.Size, g.Free, g.ExtentSize, strings.Join(g.PhysicalVolumes, ",")))
+	for _, l := range g.LogicalVolumes {
+		result.WriteString(l.String())
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+// newVolumeGroups - links physical volumes and logical volumes to their volume groups. Filesystems and mountpoints of
+// logical volumes are taken from lsblk
+func newVolumeGroups(lvm *commands.Lvm, holders map[string]*commands.LsblkDevice) []*VolumeGroup {
+
+	var result []*VolumeGroup
+	for _, g := range lvm.VolumeGroups {
+		group := &VolumeGroup{Name: g.Name, Size: g.Size, Free: g.Free, ExtentSize: g.ExtentSize, Uuid: g.Uuid}
+
+		for _, p := range lvm.PhysicalVolumes {
+			if p.VolumeGroup == g.Name {
+				group.PhysicalVolumes = append(group.PhysicalVolumes, p.Name)
+			}
+		}
+
+		for _, l := range lvm.LogicalVolumes {
+			if l.VolumeGroup != g.Name {
+				continue
+			}
+			volume := &LogicalVolume{Name: l.Name, Device: l.Path, MapperName: l.MapperName(), Size: l.Size, Attributes: l.Attributes,
+				Origin: l.Origin, Pool: l.Pool, LvUuid: l.Uuid}
+			if h, ok := holders["/dev/mapper/"+volume.MapperName]; ok {
+				volume.FileSystem, volume.Uuid, volume.Label = h.Fstype, h.Uuid, h.Label
+				if len(h.Mountpoints) > 0 {
+					volume.Mountpoint = h.Mountpoints[0]
+				}
+			}
+			group.LogicalVolumes = append(group.LogicalVolumes, volume)
+		}
+		result = append(result, group)
+	}
+	return result
+}
+
+// lvmProvider - volume groups with their physical and logical volumes
+type lvmProvider struct {
+	provider
+	lvm *commands.Lvm
+}
+
+// Collect -
+func (p *lvmProvider) Collect(ctx context.Context, d *Discovery) (err error) {
+	p.lvm, err = commands.NewLvm(ctx)
+	return err
+}
+
+// Contribute - filesystems of logical volumes are taken from the holders contributed by lsblk before
+func (p *lvmProvider) Contribute(d *Discovery) {
+	if p.lvm != nil {
+		d.System.VolumeGroups = newVolumeGroups(p.lvm, d.Holders)
+	}
+}
+
+func (p *lvmProvider) String() string {
+	if p.lvm == nil {
+		return ""
+	}
+	return p.lvm.String()
+}
@@ -10,59 +10,88 @@ package model
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"sort"
-	"sync"
 
 	"github.com/framps/raspiBackupNext/commands"
-	"github.com/framps/raspiBackupNext/tools"
-	"github.com/jinzhu/copier"
 )
 
 // Partition -
 type Partition struct {
 
 	// from parted
-	Name       string // /dev/sda1 or /dev/mmcblk0p1 or /dev/loop1
-	Number     int
-	Start      int64
-	End        int64
-	Size       int64
-	Type       string // ext4
-	FileSystem string
-	Flags      string // lba
+	Name          string // /dev/sda1 or /dev/mmcblk0p1 or /dev/loop1
+	Number        int
+	Start         int64
+	End           int64
+	Size          int64
+	Type          string // ext4
+	FileSystem    string
+	Flags         string // lba
+	PartitionName string // gpt only
+	TypeGUID      string // gpt only
+	Kind          string // primary, extended or logical
 
 	// from blkid
 	Uuid     string
 	Partuuid string
 	Label    string
 	Ptype    string
+
+	// open dm-crypt mapping of a LUKS container, e.g. /dev/mapper/cryptroot
+	Mapper string
+	// md array of a raid member, e.g. /dev/md0
+	RaidArray string
+
+	// provider of each attribute, e.g. Label: blkid
+	Sources map[string]string `json:",omitempty"`
 }
 
 func (p Partition) String() string {
-	return fmt.Sprintf("PartitionNumber: %d - Start: %d - End: %d - Size: %d - Type: %s - FileSystem: %s - Flags: %s "+
-		"UUid: %s - Partuuid: %s - Label: %s - PType: %s",
-		p.Number, p.Start, p.End, p.Size, p.Type, p.FileSystem, p.Flags,
-		p.Uuid, p.Partuuid, p.Label, p.Ptype)
+	return fmt.Sprintf("PartitionNumber: %d - Kind: %s - Start: %d - End: %d - Size: %d - Type: %s - FileSystem: %s - Flags: %s "+
+		"- Name: %s - TypeGUID: %s - UUid: %s - Partuuid: %s - Label: %s - PType: %s%s",
+		p.Number, p.Kind, p.Start, p.End, p.Size, p.Type, p.FileSystem, p.Flags,
+		p.PartitionName, p.TypeGUID, p.Uuid, p.Partuuid, p.Label, p.Ptype, p.stacked())
+}
+
+// stacked - " - Mapper: /dev/mapper/cryptroot" for LUKS containers and " - RaidArray: /dev/md0" for raid members only to
+// keep the output of other partitions unchanged
+func (p Partition) stacked() string {
+	var result string
+	if p.Mapper != "" {
+		result += " - Mapper: " + p.Mapper
+	}
+	if p.RaidArray != "" {
+		result += " - RaidArray: " + p.RaidArray
+	}
+	return result
 }
 
 // Disk -
 type Disk struct {
 	Name               string // /dev/sda or /dev/mmcblk0p or /dev/loop
 	Size               string // 1000GB
+	Transport          string // scsi, sd/mmc, nvme, ...
+	Model              string
 	SectorSizeLogical  int    // 512
 	SectorSizePhysical int    // 512
 	PartitionTableType string // msdos
 	Partitions         map[int]*Partition
+	Partial            bool // parted failed, partitions are reported by lsblk and blkid only
+
+	Sources  map[string]string `json:",omitempty"` // provider of each attribute, e.g. PartitionTableType: parted
+	Warnings []string          `json:",omitempty"` // conflicting attributes and partitions not reported by all providers
 }
 
 func (d Disk) String() string {
 	var result bytes.Buffer
-	result.WriteString(fmt.Sprintf("Name: %s - Size: %s - LogicalSectorSize: %d - PhysicalSectorSize: %d - PartitionTableType: %s\n",
-		d.Name, d.Size, d.SectorSizeLogical, d.SectorSizePhysical, d.PartitionTableType))
+	result.WriteString(fmt.Sprintf("Name: %s - Size: %s - Transport: %s - Model: %s - LogicalSectorSize: %d - PhysicalSectorSize: %d - PartitionTableType: %s",
+		d.Name, d.Size, d.Transport, d.Model, d.SectorSizeLogical, d.SectorSizePhysical, d.PartitionTableType))
+	if d.Partial {
+		result.WriteString(" - Partial")
+	}
+	result.WriteString("\n")
 
 	index := make([]*Partition, 0, len(d.Partitions))
 	for _, partition := range d.Partitions {
@@ -84,77 +113,21 @@ func (d Disk) String() string {
 // System -
 type System struct {
 	Disks         []*Disk
+	VolumeGroups  []*VolumeGroup
+	CryptDevices  []*CryptDevice
+	RaidArrays    []*RaidArray
+	Btrfs         []*BtrfsFilesystem
 	Bootpartition *commands.SystemDevice
 	Rootpartition *commands.SystemDevice
+	Timings       *commands.Timings `json:"-"` // durations of the discovery steps
 }
 
-// NewSystem -
-func NewSystem(parallelExecution bool) (*System, error) {
-
-	var (
-		err           error
-		lsblkDisks    *commands.LsblkDisks
-		blkidDisks    *commands.BlkidDisks
-		systemDevices *commands.SystemDevices
-	)
-
-	if parallelExecution {
-		var wg sync.WaitGroup
-		wg.Add(3)
-
-		// retrieve all known disks of system
-		go func() {
-			lsblkDisks, err = commands.NewLsblkDisks()
-			tools.HandleError(err)
-			wg.Done()
-		}()
-
-		go func() {
-			blkidDisks, err = commands.NewBlkidDisks()
-			tools.HandleError(err)
-			wg.Done()
-		}()
-
-		go func() {
-			systemDevices, err = commands.NewSystemDevices()
-			tools.HandleError(err)
-			wg.Done()
-		}()
-		wg.Wait()
-	} else {
-		lsblkDisks, err = commands.NewLsblkDisks()
-		blkidDisks, err = commands.NewBlkidDisks()
-		systemDevices, err = commands.NewSystemDevices()
-	}
-
-	system := System{}
-	for _, d := range lsblkDisks.Disks {
-
-		tools.Logger.Debugf("Processing disk %s", d.Name)
-		disk := Disk{Name: d.Name}
-
-		partedDisk, err := commands.NewPartedDisk("/dev/" + disk.Name)
-		tools.HandleError(err)
-
-		copier.Copy(&disk, &partedDisk)
-		system.Disks = append(system.Disks, &disk)
-
-		disk.Partitions = make(map[int]*Partition, len(d.Partitions))
-
-		for i, p := range partedDisk.Partitions {
-			partition := Partition{}
-			copier.Copy(&partition, partedDisk.Partitions[i])
-			blkidPartition := blkidDisks.Disks["/dev/"+d.Name].Partitions[i]
-			copier.Copy(&partition, &blkidPartition)
-			disk.Partitions[p.Number] = &partition
-		}
-	}
-
-	system.Bootpartition = systemDevices.Bootdevice
-	system.Bootpartition = systemDevices.Rootdevice
-
-	return &system, nil
-
+// NewSystem - discovers the system with the providers of the commands backend. All commands are cancelled if ctx is
+// done. Failures of single tools or disks are returned as commands.DiscoveryErrors together with the partially
+// discovered system. The system is nil if no disks could be discovered
+func NewSystem(ctx context.Context, parallelExecution bool) (*System, error) {
+	discovery, err := Discover(ctx, CommandProviders(), parallelExecution)
+	return discovery.System, err
 }
 
 func (s System) String() string {
@@ -178,6 +151,27 @@ func (s System) String() string {
 		}
 	}
 
+	for _, g := range s.VolumeGroups {
+		result.WriteString("\n")
+		result.WriteString(g.String())
+	}
+
+	for _, a := range s.RaidArrays {
+		result.WriteString("\n")
+		result.WriteString(a.String())
+	}
+
+	for _, f := range s.Btrfs {
+		result.WriteString("\n")
+		result.WriteString(f.String())
+	}
+
+	for _, c := range s.CryptDevices {
+		result.WriteString("\n")
+		result.WriteString(c.String())
+		result.WriteString("\n")
+	}
+
 	if s.Bootpartition != nil {
 		result.WriteString("Bootpartition - ")
 		result.WriteString(s.Bootpartition.String())
@@ -190,40 +184,11 @@ func (s System) String() string {
 	return result.String()
 }
 
-// ToJSON -
-func (s *System) ToJSON(fileName string) error {
-
-	var (
-		j   []byte
-		err error
-	)
-
-	if j, err = json.MarshalIndent(s, "", " "); err != nil {
-		return err
+// Warnings - warnings of all disks about inconsistent attributes reported by different providers
+func (s System) Warnings() []string {
+	var result []string
+	for _, disk := range s.Disks {
+		result = append(result, disk.Warnings...)
 	}
-
-	err = ioutil.WriteFile(fileName, j, os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	return nil
-
-}
-
-// NewSystemFromJSON -
-func NewSystemFromJSON(fileName string) (*System, error) {
-
-	j, err := ioutil.ReadFile(fileName)
-	if err != nil {
-		return nil, err
-	}
-
-	var s System
-	if err := json.Unmarshal(j, &s); err != nil {
-		return nil, err
-	}
-
-	return &s, nil
-
+	return result
 }
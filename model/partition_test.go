@@ -0,0 +1,220 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func replay(t *testing.T, system string) func() {
+	tools.NewLogger(false)
+	replayer, err := commands.NewReplayRunner("../commands/testData/replay_test/" + system)
+	assert.NoError(t, err)
+	previous := commands.SetRunner(replayer)
+	return func() { commands.SetRunner(previous) }
+}
+
+func TestNewSystemReplay(t *testing.T) {
+
+	defer replay(t, "raspifix")()
+
+	for _, parallel := range []bool{false, true} {
+		system, err := NewSystem(context.Background(), parallel)
+		assert.NoError(t, err)
+		assert.Len(t, system.Disks, 3)
+
+		for _, disk := range system.Disks {
+			assert.Equal(t, "msdos", disk.PartitionTableType)
+			assert.NotEmpty(t, disk.Partitions)
+			if disk.Name == "/dev/sda" {
+				assert.Equal(t, "silver", disk.Partitions[1].Label)
+				assert.Equal(t, int64(1000202043392), disk.Partitions[1].Size)
+			}
+			if disk.Name == "/dev/mmcblk0" {
+				assert.Equal(t, "boot", disk.Partitions[1].Label)
+				assert.Equal(t, "2c3ce5a2-02", disk.Partitions[2].Partuuid)
+			}
+		}
+		assert.Equal(t, "/dev/mmcblk0p1", system.Bootpartition.DeviceName)
+		assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+		assert.Contains(t, system.Timings.String(), "parted /dev/sda ")
+	}
+}
+
+// failingRunner - fails all commands of a tool with an argument, e.g. parted of /dev/sda, and replays all other commands
+type failingRunner struct {
+	runner   commands.Runner
+	command  string
+	argument string
+}
+
+func (r failingRunner) Run(c *commands.Cmd) (*commands.Invocation, error) {
+	if c.Command == r.command {
+		for _, a := range c.Arguments {
+			if r.argument == "" || a == r.argument {
+				stderr := "Error: Input/output error during read on " + r.argument + "\n"
+				return &commands.Invocation{Args: c.Args, ExitCode: 1, Stderr: stderr}, &commands.ExitStatusError{ExitCode: 1}
+			}
+		}
+	}
+	return r.runner.Run(c)
+}
+
+func TestNewSystemPartial(t *testing.T) {
+
+	defer replay(t, "raspifix")()
+	replayer := commands.CurrentRunner()
+
+	for _, parallel := range []bool{false, true} {
+		commands.SetRunner(failingRunner{runner: replayer, command: "parted", argument: "/dev/sda"})
+		system, err := NewSystem(context.Background(), parallel)
+		if assert.Error(t, err) && assert.NotNil(t, system) {
+			failures := commands.AsDiscoveryErrors(err)
+			if assert.Len(t, failures, 1) {
+				assert.Equal(t, "parted", failures[0].Tool)
+				assert.Equal(t, "/dev/sda", failures[0].Disk)
+			}
+			assert.Len(t, system.Disks, 3)
+			for _, disk := range system.Disks {
+				assert.Equal(t, disk.Name == "/dev/sda", disk.Partial, disk.Name)
+				if disk.Name == "/dev/sda" {
+					assert.Equal(t, "silver", disk.Partitions[1].Label)
+					assert.Empty(t, disk.PartitionTableType)
+					assert.Contains(t, disk.String(), " - Partial\n")
+				}
+			}
+			assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+		}
+
+		commands.SetRunner(failingRunner{runner: replayer, command: "findmnt"})
+		system, err = NewSystem(context.Background(), parallel)
+		if assert.Error(t, err) && assert.NotNil(t, system) {
+			assert.Contains(t, err.Error(), "findmnt failed: ")
+			assert.Nil(t, system.Rootpartition)
+		}
+
+		commands.SetRunner(failingRunner{runner: replayer, command: "lsblk"})
+		system, err = NewSystem(context.Background(), parallel)
+		assert.Nil(t, system)
+		var commandError *commands.CommandError
+		assert.True(t, errors.As(err, &commandError))
+	}
+}
+
+func TestNewSystemLvm(t *testing.T) {
+
+	defer replay(t, "lvm")()
+
+	system, err := NewSystem(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Len(t, system.Disks, 2)
+	assert.Equal(t, "/dev/mmcblk0p1", system.Bootpartition.DeviceName)
+	assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+
+	if assert.Len(t, system.VolumeGroups, 1) {
+		group := system.VolumeGroups[0]
+		assert.Equal(t, "data", group.Name)
+		assert.Equal(t, []string{"/dev/sda1"}, group.PhysicalVolumes)
+		if assert.Len(t, group.LogicalVolumes, 2) {
+			home := group.LogicalVolumes[0]
+			assert.Equal(t, "/dev/data/home", home.Device)
+			assert.Equal(t, "ext4", home.FileSystem)
+			assert.Equal(t, "home", home.Label)
+			assert.Equal(t, "/home", home.Mountpoint)
+			assert.Equal(t, "swap", group.LogicalVolumes[1].FileSystem)
+		}
+	}
+	assert.Contains(t, system.String(), "VolumeGroup: data - Size: 500106813440")
+}
+
+func TestNewSystemLuks(t *testing.T) {
+
+	defer replay(t, "luks")()
+
+	system, err := NewSystem(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/mmcblk0p1", system.Bootpartition.DeviceName)
+	assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+
+	if assert.Len(t, system.CryptDevices, 1) {
+		crypt := system.CryptDevices[0]
+		assert.Equal(t, "/dev/mapper/cryptroot", crypt.Device)
+		assert.Equal(t, "/dev/mmcblk0p2", crypt.Container)
+		assert.Equal(t, "LUKS2", crypt.Type)
+		assert.Equal(t, int64(32768), crypt.Offset)
+		assert.Equal(t, "ext4", crypt.FileSystem)
+		assert.Equal(t, "/", crypt.Mountpoint)
+		assert.True(t, crypt.IsLuks())
+	}
+	assert.Equal(t, "/dev/mapper/cryptroot", system.Disks[0].Partitions[2].Mapper)
+	assert.Equal(t, "crypto_LUKS", system.Disks[0].Partitions[2].Type)
+	assert.Contains(t, system.String(), "Mapper: /dev/mapper/cryptroot")
+}
+
+func TestNewSystemRaid(t *testing.T) {
+
+	defer replay(t, "raid")()
+
+	system, err := NewSystem(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Len(t, system.Disks, 3)
+	assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+
+	if assert.Len(t, system.RaidArrays, 1) {
+		array := system.RaidArrays[0]
+		assert.Equal(t, "/dev/md0", array.Device)
+		assert.Equal(t, "raid1", array.Level)
+		assert.True(t, array.Degraded)
+		assert.Equal(t, 1, array.ActiveDevices)
+		assert.Equal(t, "nas:0", array.MdName)
+		assert.Equal(t, "ext4", array.FileSystem)
+		assert.Equal(t, "/srv/nas", array.Mountpoint)
+		assert.Equal(t, "0", array.Member("/dev/sda1").Role)
+		assert.Equal(t, "faulty", array.Member("/dev/sdb1").Role)
+	}
+
+	for _, d := range system.Disks {
+		if d.Name == "/dev/mmcblk0" {
+			assert.Empty(t, d.Partitions[2].RaidArray)
+			continue
+		}
+		assert.Equal(t, "/dev/md0", d.Partitions[1].RaidArray)
+		assert.Equal(t, "linux_raid_member", d.Partitions[1].Type)
+	}
+	assert.Contains(t, system.String(), "RaidArray: md0 - Device: /dev/md0 - Level: raid1")
+}
+
+func TestNewSystemBtrfs(t *testing.T) {
+
+	defer replay(t, "btrfs")()
+
+	system, err := NewSystem(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+
+	if assert.Len(t, system.Btrfs, 1) {
+		fs := system.Btrfs[0]
+		assert.Equal(t, "/dev/mmcblk0p2", fs.Device)
+		assert.Equal(t, "rootfs", fs.Label)
+		assert.Equal(t, 256, fs.DefaultID)
+		assert.Len(t, fs.Subvolumes, 4)
+		assert.Equal(t, []string{"/"}, fs.Subvolume(256).Mountpoints)
+		assert.True(t, fs.Subvolume(256).Default)
+		assert.Equal(t, []string{"/home"}, fs.Subvolume(257).Mountpoints)
+		assert.True(t, fs.Subvolume(262).IsSnapshot())
+		assert.Empty(t, fs.Subvolume(262).Mountpoints)
+	}
+	assert.Contains(t, system.String(), "Subvolume: @home - ID: 257")
+}
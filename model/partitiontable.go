@@ -0,0 +1,133 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/fsprobe"
+	"github.com/framps/raspiBackupNext/partitiontable"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// applyPartitionTable - sets the partition table type and the partition attributes read from the partition table.
+// Partitions not known yet are added
+func applyPartitionTable(disk *Disk, device string, table *partitiontable.Table) {
+
+	disk.PartitionTableType = table.Type
+	if disk.Partitions == nil {
+		disk.Partitions = make(map[int]*Partition, len(table.Partitions))
+	}
+
+	for _, p := range table.Partitions {
+		partition, ok := disk.Partitions[p.Number]
+		if !ok {
+			partition = &Partition{Name: commands.PartitionName(device, p.Number), Number: p.Number, Start: p.Start, End: p.End(), Size: p.Size}
+			disk.Partitions[p.Number] = partition
+		}
+		partition.Kind, partition.TypeGUID, partition.PartitionName, partition.Partuuid = p.Kind, p.TypeGUID, p.Name, p.Partuuid
+		if p.Bootable {
+			partition.Flags = "boot"
+		}
+	}
+}
+
+// NewDiskFromPartitionTable - disk of a device or image with the partitions of its partition table
+func NewDiskFromPartitionTable(name string, size int64, table *partitiontable.Table) *Disk {
+	disk := &Disk{Name: name, Size: fmt.Sprintf("%dB", size), SectorSizeLogical: table.SectorSize}
+	applyPartitionTable(disk, name, table)
+	return disk
+}
+
+// NewDiskFromImage - disk of an image file, e.g. a dd backup, with the filesystems of its partitions
+func NewDiskFromImage(fileName string) (*Disk, error) {
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := partitiontable.Read(f, partitiontable.DefaultSectorSize)
+	if err != nil {
+		tools.Logger.Errorf("NewDiskFromImage failed for %s: %s", fileName, err.Error())
+		return nil, err
+	}
+	disk := NewDiskFromPartitionTable(fileName, fi.Size(), table)
+
+	for _, p := range table.Partitions {
+		if p.Kind == partitiontable.KindExtended {
+			continue
+		}
+		if fs, err := fsprobe.Probe(io.NewSectionReader(f, p.Start, p.Size)); err == nil {
+			applyFilesystem(disk.Partitions[p.Number], fs)
+		}
+	}
+	return disk, nil
+}
+
+// partitionTableProvider - partition tables read directly from the disks contributed before. Disks are readable for
+// root or members of group disk only
+type partitionTableProvider struct {
+	provider
+	root   string // root of the dev directory
+	tables map[string]*partitiontable.Table
+}
+
+// Collect -
+func (p *partitionTableProvider) Collect(ctx context.Context, d *Discovery) error {
+	p.tables = make(map[string]*partitiontable.Table)
+	for _, name := range d.Disks() {
+		table, err := partitiontable.ReadFile(filepath.Join(p.root, "dev", name), d.Disk(name).SectorSizeLogical)
+		if err != nil {
+			tools.Logger.Debugf("Partition table of %s not available: %s", name, err.Error())
+			continue
+		}
+		p.tables[name] = table
+	}
+	return ctx.Err()
+}
+
+// Contribute -
+func (p *partitionTableProvider) Contribute(d *Discovery) {
+	for name, table := range p.tables {
+		disk := &Disk{}
+		applyPartitionTable(disk, "/dev/"+name, table)
+		d.AddDisk(p, name, *disk)
+		for _, partition := range disk.Partitions {
+			d.AddPartition(p, name, *partition)
+		}
+	}
+}
+
+func (p *partitionTableProvider) String() string {
+	names := make([]string, 0, len(p.tables))
+	for name := range p.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result bytes.Buffer
+	for _, name := range names {
+		result.WriteString(fmt.Sprintf("Disk: %s\n%s", name, p.tables[name]))
+	}
+	return result.String()
+}
@@ -0,0 +1,204 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/jinzhu/copier"
+)
+
+// CommandProviders - providers of the commands backend which uses lsblk, blkid, parted, findmnt, lvm, mdadm,
+// cryptsetup and btrfs. Superblocks are cross checked on the running system only
+func CommandProviders() []Provider {
+	providers := []Provider{
+		&lsblkProvider{provider: provider{"lsblk", PriorityInventory, 0}},
+		&blkidProvider{provider: provider{"blkid", PriorityProbe, 0}},
+		&mountTableProvider{provider: provider{"findmnt", 0, 0}, read: commands.NewMountTable},
+		&lvmProvider{provider: provider{"lvm", 0, 0}},
+		&partedProvider{provider: provider{"parted", PriorityPartitionTable, 1}},
+		&mdadmProvider{provider: provider{"mdadm", 0, 1}},
+		&cryptsetupProvider{provider: provider{"cryptsetup", 0, 1}},
+		&btrfsProvider{provider: provider{"btrfs", 0, 2}, subvolumes: true},
+	}
+	// blkid results are cross checked with the superblocks on the running system only
+	if _, live := commands.CurrentRunner().(commands.ExecRunner); live {
+		providers = append(providers, &superblockProvider{provider: provider{"superblock", PrioritySuperblock, 2}, root: "/"})
+	}
+	return providers
+}
+
+// lsblkProvider - disks and partitions with their filesystems and all devices stacked on them
+type lsblkProvider struct {
+	provider
+	disks *commands.LsblkDisks
+}
+
+// Collect -
+func (p *lsblkProvider) Collect(ctx context.Context, d *Discovery) (err error) {
+	p.disks, err = commands.NewLsblkDisks(ctx)
+	return err
+}
+
+// Contribute -
+func (p *lsblkProvider) Contribute(d *Discovery) {
+	if p.disks == nil {
+		return
+	}
+	for _, disk := range p.disks.Disks {
+		d.AddDisk(p, disk.Name, Disk{Name: "/dev/" + disk.Name, Size: fmt.Sprintf("%dB", disk.Size), Transport: disk.Tran, Model: disk.Model})
+		for _, partition := range disk.Partitions {
+			contributed := Partition{Name: "/dev/" + partition.Name, Number: partition.Number, Size: partition.Size,
+				Type: partition.Fstype, PartitionName: partition.Partlabel, Uuid: partition.Uuid, Partuuid: partition.Partuuid,
+				Label: partition.Label}
+			// msdos partition types like 0x83 are no GUIDs
+			if disk.Pttype == "gpt" {
+				contributed.TypeGUID = partition.Parttype
+			}
+			d.AddPartition(p, disk.Name, contributed)
+		}
+	}
+	d.Devices = p.disks.Devices()
+	d.Holders = p.disks.Holders()
+}
+
+func (p *lsblkProvider) String() string {
+	if p.disks == nil {
+		return ""
+	}
+	return p.disks.String()
+}
+
+// blkidProvider - filesystems of the partitions of the disks contributed before
+type blkidProvider struct {
+	provider
+	disks *commands.BlkidDisks
+}
+
+// Collect -
+func (p *blkidProvider) Collect(ctx context.Context, d *Discovery) (err error) {
+	p.disks, err = commands.NewBlkidDisks(ctx)
+	return err
+}
+
+// Contribute - blkid reports devices which are no disks, e.g. device mapper devices, as well. They are ignored
+func (p *blkidProvider) Contribute(d *Discovery) {
+	if p.disks == nil {
+		return
+	}
+	for _, name := range d.Disks() {
+		disk, ok := p.disks.Disks["/dev/"+name]
+		if !ok {
+			continue
+		}
+		for _, b := range disk.Partitions {
+			partition := Partition{}
+			copier.Copy(&partition, b)
+			d.AddPartition(p, name, partition)
+		}
+	}
+}
+
+func (p *blkidProvider) String() string {
+	if p.disks == nil {
+		return ""
+	}
+	return p.disks.String()
+}
+
+// mountTableProvider - mount table used to resolve the boot and root partitions and the mountpoints of stacked devices
+type mountTableProvider struct {
+	provider
+	read  func(ctx context.Context) (*sysfs.MountTable, error)
+	table *sysfs.MountTable
+}
+
+// Collect -
+func (p *mountTableProvider) Collect(ctx context.Context, d *Discovery) (err error) {
+	p.table, err = p.read(ctx)
+	return err
+}
+
+// Contribute -
+func (p *mountTableProvider) Contribute(d *Discovery) {
+	if p.table != nil {
+		d.MountTable = p.table
+	}
+}
+
+func (p *mountTableProvider) String() string {
+	if p.table == nil {
+		return ""
+	}
+	return p.table.String()
+}
+
+// partedProvider - partition tables of all disks contributed before. Disks are probed concurrently
+type partedProvider struct {
+	provider
+	names    []string
+	disks    []*commands.PartedDisk
+	failures []error
+}
+
+// Collect - card readers without medium are removed, disks parted failed for are marked as partial
+func (p *partedProvider) Collect(ctx context.Context, d *Discovery) error {
+
+	p.names = d.Disks()
+	p.disks = make([]*commands.PartedDisk, len(p.names))
+	p.failures = make([]error, len(p.names))
+	commands.ForEach(ctx, len(p.names), d.workers, func(i int) {
+		defer d.Timings.Measure("parted /dev/"+p.names[i], time.Now())
+		p.disks[i], p.failures[i] = commands.NewPartedDisk(ctx, "/dev/"+p.names[i])
+	})
+
+	var errs commands.DiscoveryErrors
+	for i, name := range p.names {
+		if !commands.IsNoMedium(p.failures[i]) {
+			errs.Add("parted", "/dev/"+name, p.failures[i])
+		}
+	}
+	return errs.Err()
+}
+
+// Contribute -
+func (p *partedProvider) Contribute(d *Discovery) {
+	for i, name := range p.names {
+		switch {
+		case commands.IsNoMedium(p.failures[i]):
+			d.RemoveDisk(name)
+		case p.failures[i] != nil:
+			d.AddDisk(p, name, Disk{Partial: true})
+		case p.disks[i] != nil:
+			disk := Disk{}
+			copier.Copy(&disk, p.disks[i])
+			d.AddDisk(p, name, disk)
+			for _, pp := range p.disks[i].Partitions {
+				partition := Partition{}
+				copier.Copy(&partition, pp)
+				d.AddPartition(p, name, partition)
+			}
+		}
+	}
+}
+
+func (p *partedProvider) String() string {
+	var result bytes.Buffer
+	for _, disk := range p.disks {
+		if disk != nil {
+			result.WriteString(disk.String())
+		}
+	}
+	return result.String()
+}
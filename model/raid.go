@@ -0,0 +1,219 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/fsprobe"
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// RaidMember -
+type RaidMember struct {
+	Device string // /dev/sda1
+	Role   string // slot number, spare or faulty
+}
+
+// RaidArray - md software raid array
+type RaidArray struct {
+
+	// from /proc/mdstat
+	Name          string // md0
+	Device        string // /dev/md0
+	Level         string // raid1, empty for inactive arrays
+	State         string // active or inactive
+	ReadOnly      bool
+	Degraded      bool
+	RaidDevices   int
+	ActiveDevices int
+	Sync          string // recovery = 8.5%
+	Size          int64
+	Members       []*RaidMember
+
+	// from mdadm --detail
+	Metadata string
+	MdUuid   string
+	MdName   string // nas:0
+
+	// from lsblk or the superblock
+	FileSystem string
+	Uuid       string
+	Label      string
+	Mountpoint string
+}
+
+func (a RaidArray) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("RaidArray: %s - Device: %s - Level: %s - State: %s - Degraded: %t - Devices: %d/%d - Sync: %s - Size: %d - MdName: %s - FileSystem: %s - Uuid: %s - Label: %s - Mountpoint: %s\n",
+		a.Name, a.Device, a.Level, a.State, a.Degraded, a.ActiveDevices, a.RaidDevices, a.Sync, a.Size, a.MdName, a.FileSystem, a.Uuid, a.Label, a.Mountpoint))
+	for _, m := range a.Members {
+		result.WriteString(fmt.Sprintf("Member: %s - Role: %s\n", m.Device, m.Role))
+	}
+	return result.String()
+}
+
+// Member - member with device, e.g. /dev/sda1, or nil
+func (a RaidArray) Member(device string) *RaidMember {
+	for _, m := range a.Members {
+		if m.Device == device {
+			return m
+		}
+	}
+	return nil
+}
+
+// newRaidArray - array of /proc/mdstat. Roles of members are taken from mdstat until mdadm reports them
+func newRaidArray(md *sysfs.MdArray) *RaidArray {
+	array := &RaidArray{Name: md.Name, Device: "/dev/" + md.Name, Level: md.Level, State: md.State, ReadOnly: md.ReadOnly,
+		Degraded: md.Degraded(), RaidDevices: md.RaidDisks, ActiveDevices: md.ActiveDisks, Sync: md.Sync, Size: md.Blocks * 1024}
+	for _, m := range md.Members {
+		role := strconv.Itoa(m.Role)
+		switch {
+		case m.Faulty:
+			role = "faulty"
+		case m.Spare:
+			role = "spare"
+		}
+		array.Members = append(array.Members, &RaidMember{Device: "/dev/" + m.Name, Role: role})
+	}
+	return array
+}
+
+// newRaidArrays - arrays of /proc/mdstat with the details of mdadm and the filesystem reported by lsblk. Without mdadm
+// only the mdstat attributes are available
+func newRaidArrays(ctx context.Context, mdstat []*sysfs.MdArray, holders map[string]*commands.LsblkDevice) []*RaidArray {
+
+	var result []*RaidArray
+	for _, md := range mdstat {
+		array := newRaidArray(md)
+
+		if h, ok := holders[array.Device]; ok {
+			array.FileSystem, array.Uuid, array.Label = h.Fstype, h.Uuid, h.Label
+			if len(h.Mountpoints) > 0 {
+				array.Mountpoint = h.Mountpoints[0]
+			}
+		}
+
+		detail, err := commands.NewMdDetail(ctx, array.Device)
+		switch {
+		case err == nil:
+			array.Metadata, array.MdUuid, array.MdName = detail.Metadata, detail.Uuid, detail.Name
+			for _, d := range detail.Members {
+				if m := array.Member(d.Device); m != nil {
+					m.Role = d.Role
+				}
+			}
+		case commands.IsNotFound(err):
+			tools.Logger.Debugf("mdadm not installed, details of %s not available", array.Device)
+		default:
+			tools.Logger.Warnf("Details of %s not available: %s", array.Device, err.Error())
+		}
+		result = append(result, array)
+	}
+	return result
+}
+
+// newRaidArraysFromSysfs - arrays of /proc/mdstat. The filesystem is read from the superblock of the array if it's
+// readable. Arrays are added to devices to resolve mount sources on arrays
+func newRaidArraysFromSysfs(reader *sysfs.Reader, mdstat []*sysfs.MdArray, mountTable *sysfs.MountTable, devices *sysfs.Devices) []*RaidArray {
+
+	var result []*RaidArray
+	for _, md := range mdstat {
+		array := newRaidArray(md)
+		if fs, err := fsprobe.ProbeFile(filepath.Join(reader.Root, "dev", md.Name)); err == nil {
+			array.FileSystem, array.Uuid, array.Label = fs.Type, fs.Uuid, fs.Label
+		}
+		if dev, err := reader.Dev(md.Name); err == nil {
+			if mount := mountTable.FindDev(dev); mount != nil {
+				array.Mountpoint = mount.Mountpoint
+			}
+			devices.Add(array.Device, dev, "", array.Uuid, array.Label)
+		}
+		result = append(result, array)
+	}
+	return result
+}
+
+// linkRaidArrays - sets the array of the member partitions
+func linkRaidArrays(disks []*Disk, arrays []*RaidArray) {
+	for _, a := range arrays {
+		for _, d := range disks {
+			for _, p := range d.Partitions {
+				if a.Member(p.Name) != nil {
+					p.RaidArray = a.Device
+				}
+			}
+		}
+	}
+}
+
+// mdadmProvider - arrays of /proc/mdstat with the details of mdadm
+type mdadmProvider struct {
+	provider
+	arrays []*RaidArray
+}
+
+// Collect -
+func (p *mdadmProvider) Collect(ctx context.Context, d *Discovery) error {
+	mdstat, err := commands.NewMdstat(ctx)
+	if err != nil {
+		return err
+	}
+	p.arrays = newRaidArrays(ctx, mdstat, d.Holders)
+	return ctx.Err()
+}
+
+// Contribute -
+func (p *mdadmProvider) Contribute(d *Discovery) {
+	d.System.RaidArrays = p.arrays
+}
+
+func (p *mdadmProvider) String() string {
+	var result bytes.Buffer
+	for _, a := range p.arrays {
+		result.WriteString(a.String())
+	}
+	return result.String()
+}
+
+// mdstatProvider - arrays of /proc/mdstat read directly
+type mdstatProvider struct {
+	provider
+	reader *sysfs.Reader
+	mdstat []*sysfs.MdArray
+}
+
+// Collect -
+func (p *mdstatProvider) Collect(ctx context.Context, d *Discovery) (err error) {
+	p.mdstat, err = p.reader.Mdstat()
+	return err
+}
+
+// Contribute - arrays are added to the devices and therefore created sequentially
+func (p *mdstatProvider) Contribute(d *Discovery) {
+	if d.MountTable != nil {
+		d.System.RaidArrays = newRaidArraysFromSysfs(p.reader, p.mdstat, d.MountTable, d.Devices)
+	}
+}
+
+func (p *mdstatProvider) String() string {
+	var result bytes.Buffer
+	for _, md := range p.mdstat {
+		result.WriteString(md.String())
+		result.WriteString("\n")
+	}
+	return result.String()
+}
@@ -0,0 +1,149 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+var (
+	// disk attributes which have to be consistent, e.g. Model differs between lsblk and parted on most USB disks
+	reconciledDiskFields = []string{"Size", "SectorSizeLogical", "PartitionTableType"}
+	// partition attributes which have to be consistent
+	reconciledPartitionFields = []string{"Start", "End", "Size", "Type", "FileSystem", "Uuid", "Partuuid", "Label",
+		"PartitionName", "TypeGUID"}
+	reconciledExtendedFields = []string{"Start", "Type", "Uuid", "Partuuid", "Label", "PartitionName", "TypeGUID"}
+
+	// filesystem types reported differently by the tools, e.g. parted reports fat32 and blkid vfat
+	filesystemAliases = map[string]string{
+		"fat12": "vfat", "fat16": "vfat", "fat32": "vfat",
+		"linux-swap": "swap", "linux-swap(v1)": "swap", "linux-swap(new)": "swap", "linux-swap(old)": "swap",
+	}
+)
+
+// filesystem - common name of a filesystem type
+func filesystem(name string) string {
+	if alias, ok := filesystemAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// equal - values of a field are equal. Filesystem types are compared by their common name
+func equal(name string, v1, v2 reflect.Value) bool {
+	if name == "Type" || name == "FileSystem" {
+		return filesystem(v1.String()) == filesystem(v2.String())
+	}
+	return reflect.DeepEqual(v1.Interface(), v2.Interface())
+}
+
+// sources - provider of the value of each field
+func (f facts) sources() map[string]string {
+	result := make(map[string]string, len(f))
+	for name := range f {
+		result[name] = f.value(name).provider
+	}
+	return result
+}
+
+// providers - adds the providers which contributed any of the facts. Only providers which list partitions, i.e.
+// lsblk, parted, sysfs and the partition table, are added if listersOnly is set
+func (f facts) providers(result map[string]bool, listersOnly bool) map[string]bool {
+	for _, values := range f {
+		for _, v := range values {
+			if !listersOnly || v.priority == PriorityInventory || v.priority == PriorityPartitionTable {
+				result[v.provider] = true
+			}
+		}
+	}
+	return result
+}
+
+// reconcile - warnings about conflicting attributes of a disk and its partitions and about partitions not reported by
+// all providers listing partitions. Conflicts of other attributes are logged only
+func (f *diskFacts) reconcile(disk *Disk) []string {
+
+	var result []string
+	check := func(device string, facts facts, reconciled []string) {
+		for _, name := range sortedNames(facts) {
+			for _, conflict := range facts.conflict(name) {
+				if contains(reconciled, name) {
+					result = append(result, fmt.Sprintf("%s: %s", device, conflict))
+				} else {
+					tools.Logger.Debugf("%s: %s", device, conflict)
+				}
+			}
+		}
+	}
+
+	check(disk.Name, f.disk, reconciledDiskFields)
+	listers := f.disk.providers(make(map[string]bool), true)
+	for _, partition := range f.partitions {
+		partition.providers(listers, true)
+	}
+
+	for _, number := range sortedNumbers(f.partitions) {
+		partition := disk.Partitions[number]
+		reconciled := reconciledPartitionFields
+		if partition.Kind == "extended" { // the kernel reports the size of the extended boot record only
+			reconciled = reconciledExtendedFields
+		}
+		check(partition.Name, f.partitions[number], reconciled)
+
+		// partitions of partial disks are never reported by parted
+		if disk.Partial {
+			continue
+		}
+		reported := f.partitions[number].providers(make(map[string]bool), false)
+		var missing []string
+		for lister := range listers {
+			if !reported[lister] {
+				missing = append(missing, lister)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			result = append(result, fmt.Sprintf("%s: partition reported by %s but not by %s", partition.Name,
+				strings.Join(sortedKeys(reported), ", "), strings.Join(missing, ", ")))
+		}
+	}
+	return result
+}
+
+func sortedNames(f facts) []string {
+	result := make([]string, 0, len(f))
+	for name := range f {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func sortedNumbers(partitions map[int]facts) []int {
+	result := make([]int, 0, len(partitions))
+	for number := range partitions {
+		result = append(result, number)
+	}
+	sort.Ints(result)
+	return result
+}
+
+func sortedKeys(m map[string]bool) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,73 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+
+	defer replay(t, "raid")()
+
+	providers := []Provider{
+		&factProvider{provider: provider{"lsblk", PriorityInventory, 0}, disk: Disk{Model: "Elements", Size: "1000B"},
+			partitions: []Partition{{Number: 1, Type: "vfat", Label: "BOOT"}, {Number: 2, Size: 1000},
+				{Number: 3, Type: "ext4"}, {Number: 4, Size: 1024}}},
+		&factProvider{provider: provider{"blkid", PriorityProbe, 0},
+			partitions: []Partition{{Number: 1, Label: "boot"}, {Number: 2, Label: "root"}, {Number: 5, Type: "swap"}}},
+		&factProvider{provider: provider{"parted", PriorityPartitionTable, 1}, disk: Disk{Model: "WD Elements", Size: "1000B"},
+			partitions: []Partition{{Number: 1, Type: "fat32", Start: 2048}, {Number: 2, Size: 2000},
+				{Number: 4, Size: 4096, Kind: "extended"}}},
+		&factProvider{provider: provider{"superblock", PrioritySuperblock, 2},
+			partitions: []Partition{{Number: 2, Label: "rootfs"}}},
+	}
+	discovery, err := Discover(context.Background(), providers, false)
+	assert.NoError(t, err)
+	if assert.Len(t, discovery.System.Disks, 1) {
+		disk := discovery.System.Disks[0]
+		assert.Equal(t, []string{
+			"/dev/sda1: Label is boot from blkid but BOOT from lsblk",
+			"/dev/sda2: Label is rootfs from superblock but root from blkid",
+			"/dev/sda2: Size is 2000 from parted but 1000 from lsblk",
+			"/dev/sda3: partition reported by lsblk but not by parted",
+			"/dev/sda5: partition reported by blkid but not by lsblk, parted",
+		}, disk.Warnings)
+		assert.Equal(t, disk.Warnings, discovery.System.Warnings())
+		assert.Equal(t, "parted", disk.Sources["Model"])
+		assert.Equal(t, "blkid", disk.Partitions[1].Sources["Label"])
+		assert.Equal(t, "parted", disk.Partitions[1].Sources["Start"])
+		assert.Equal(t, "superblock", disk.Partitions[2].Sources["Label"])
+	}
+
+	// partitions of partial disks are reported by lsblk only
+	providers[2] = &factProvider{provider: provider{"parted", PriorityPartitionTable, 1}, disk: Disk{Partial: true}}
+	discovery, err = Discover(context.Background(), providers, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/dev/sda1: Label is boot from blkid but BOOT from lsblk",
+		"/dev/sda2: Label is rootfs from superblock but root from blkid",
+	}, discovery.System.Warnings())
+}
+
+func TestReconcileReplay(t *testing.T) {
+
+	// parted reports fat16 and fat32, blkid and lsblk report vfat
+	for _, system := range []string{"raspifix", "lvm", "luks", "raid", "btrfs"} {
+		func() {
+			defer replay(t, system)()
+			s, err := NewSystem(context.Background(), false)
+			assert.NoError(t, err)
+			assert.Empty(t, s.Warnings(), system)
+		}()
+	}
+}
@@ -0,0 +1,188 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Model file - the discovered system is stored as JSON next to a backup and read again by restores, possibly years and
+// many raspiBackup versions later. The file is an object with the members
+//
+//	Version - schema version of the file, see SchemaVersion
+//	System  - the System with all its disks, partitions and stacked devices. Members are named like the fields of the
+//	          structs, e.g. Disks, Partitions or Uuid. Partitions are keyed by their number, Timings are not stored
+//
+// Schema versions
+//
+//	1 - files written before the schema was versioned contain the System object only
+//	2 - System is wrapped into an object with the schema Version
+//
+// Files of older versions are migrated to the current version when they are read. Files of newer versions, unknown
+// members and inconsistent content are rejected. Any incompatible change of the JSON representation of System requires
+// a new schema version and a migration from the previous version
+
+// SchemaVersion - schema version of the model files written
+const SchemaVersion = 2
+
+// modelFile - model file of the current schema version
+type modelFile struct {
+	Version int
+	System  *System
+}
+
+// migrations - migrations[v] migrates the decoded JSON of a model file of version v to version v+1
+var migrations = map[int]func(file map[string]interface{}) (map[string]interface{}, error){
+	1: func(file map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"Version": 2, "System": file}, nil
+	},
+}
+
+// schemaVersion - version of a decoded model file. Files without version are version 1
+func schemaVersion(file map[string]interface{}) (int, error) {
+	value, ok := file["Version"]
+	if !ok {
+		return 1, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("Invalid schema version %v", value)
+	}
+	version, err := number.Int64()
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("Invalid schema version %s", number)
+	}
+	if version > SchemaVersion {
+		return 0, fmt.Errorf("Schema version %d is not supported, the model file was written by a newer raspiBackup which supports version %d",
+			version, SchemaVersion)
+	}
+	return int(version), nil
+}
+
+// migrate - migrates a model file of any supported version to the current version
+func migrate(j []byte) ([]byte, error) {
+
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.UseNumber() // keeps offsets and sizes exact
+	var file map[string]interface{}
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Model file contains data after the model")
+	}
+	if file == nil {
+		return nil, fmt.Errorf("Model file contains no object")
+	}
+
+	version, err := schemaVersion(file)
+	if err != nil {
+		return nil, err
+	}
+	for ; version < SchemaVersion; version++ {
+		if file, err = migrations[version](file); err != nil {
+			return nil, fmt.Errorf("Migration of schema version %d failed: %s", version, err.Error())
+		}
+	}
+	return json.Marshal(file)
+}
+
+// validate - checks the consistency of a system read from a model file
+func (s *System) validate() error {
+
+	names := make(map[string]bool, len(s.Disks))
+	for i, disk := range s.Disks {
+		switch {
+		case disk == nil:
+			return fmt.Errorf("Disk %d is empty", i)
+		case disk.Name == "":
+			return fmt.Errorf("Disk %d has no name", i)
+		case names[disk.Name]:
+			return fmt.Errorf("Disk %s is duplicate", disk.Name)
+		}
+		names[disk.Name] = true
+
+		for number, partition := range disk.Partitions {
+			switch {
+			case partition == nil:
+				return fmt.Errorf("Partition %d of disk %s is empty", number, disk.Name)
+			case partition.Number != number:
+				return fmt.Errorf("Partition %d of disk %s has number %d", number, disk.Name, partition.Number)
+			case partition.Name == "":
+				return fmt.Errorf("Partition %d of disk %s has no name", number, disk.Name)
+			case partition.Start < 0 || partition.End < partition.Start:
+				return fmt.Errorf("Partition %s has invalid start %d and end %d", partition.Name, partition.Start, partition.End)
+			}
+		}
+	}
+	return nil
+}
+
+// ToJSON - writes the system as model file of the current schema version. The file is readable by the owner only and
+// replaced atomically, a backup never refers to a partially written model file
+func (s *System) ToJSON(fileName string) error {
+
+	j, err := json.MarshalIndent(modelFile{Version: SchemaVersion, System: s}, "", " ")
+	if err != nil {
+		return err
+	}
+
+	// TempFile creates the file with mode 0600
+	f, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(j); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fileName)
+}
+
+// NewSystemFromJSON - reads a model file of any supported schema version. Files of older versions are migrated, files
+// with unknown members or inconsistent content are rejected
+func NewSystemFromJSON(fileName string) (*System, error) {
+
+	j, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if j, err = migrate(j); err != nil {
+		return nil, fmt.Errorf("Invalid model file %s: %s", fileName, err.Error())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.DisallowUnknownFields()
+	var file modelFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("Invalid model file %s: %s", fileName, err.Error())
+	}
+	if file.System == nil {
+		return nil, fmt.Errorf("Invalid model file %s: System is missing", fileName)
+	}
+	if err := file.System.validate(); err != nil {
+		return nil, fmt.Errorf("Invalid model file %s: %s", fileName, err.Error())
+	}
+
+	return file.System, nil
+}
@@ -0,0 +1,112 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelFile(t *testing.T) {
+
+	defer replay(t, "luks")()
+
+	dir, err := ioutil.TempDir("", "raspiBackupTest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	system, err := NewSystem(context.Background(), false)
+	assert.NoError(t, err)
+
+	fileName := filepath.Join(dir, "system.model")
+	assert.NoError(t, system.ToJSON(fileName))
+	info, err := os.Stat(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	expected, err := ioutil.ReadFile("testData/system_v2.model")
+	assert.NoError(t, err)
+	actual, err := ioutil.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+
+	read, err := NewSystemFromJSON(fileName)
+	assert.NoError(t, err)
+	if assert.NotNil(t, read) {
+		assert.Equal(t, system.String(), read.String())
+		assert.Len(t, read.CryptDevices, 1)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestModelFileV1(t *testing.T) {
+
+	// written by ToJSON of the first raspiBackupNext version, i.e. before the schema was versioned
+	system, err := NewSystemFromJSON("testData/system_v1.model")
+	assert.NoError(t, err)
+	if assert.NotNil(t, system) && assert.Len(t, system.Disks, 1) {
+		disk := system.Disks[0]
+		assert.Equal(t, "/dev/mmcblk0", disk.Name)
+		assert.Equal(t, "31116288s", disk.Size)
+		assert.Equal(t, "msdos", disk.PartitionTableType)
+		if assert.Len(t, disk.Partitions, 2) {
+			assert.Equal(t, Partition{Name: "/dev/mmcblk0p1", Number: 1, Start: 8192, End: 122879, Size: 114688,
+				Type: "vfat", Flags: "lba", Uuid: "3312-932F", Partuuid: "N/A", Label: "boot"}, *disk.Partitions[1])
+			assert.Equal(t, "system", disk.Partitions[2].Label)
+		}
+		assert.Equal(t, "/dev/mmcblk0p1", system.Bootpartition.DeviceName)
+		assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+	}
+}
+
+func TestModelFileInvalid(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "raspiBackupTest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{`{"Version": 3, "System": {}}`, "Schema version 3 is not supported"},
+		{`{"Version": 0, "System": {}}`, "Invalid schema version 0"},
+		{`{"Version": "2", "System": {}}`, "Invalid schema version 2"},
+		{`{"Version": 2}`, "System is missing"},
+		{`{"Version": 2, "System": {"Disks": [], "Disk": []}}`, `unknown field "Disk"`},
+		{`{"Disks": [{"Name": "/dev/sda", "Size": 1000}]}`, "cannot unmarshal number"},
+		{`{"Disks": [{"Name": "/dev/sda"}, {"Name": "/dev/sda"}]}`, "Disk /dev/sda is duplicate"},
+		{`{"Disks": [{"Name": "/dev/sda", "Partitions": {"1": {"Name": "/dev/sda2", "Number": 2}}}]}`,
+			"Partition 1 of disk /dev/sda has number 2"},
+		{`{"Disks": [{"Name": "/dev/sda", "Partitions": {"1": {"Name": "/dev/sda1", "Number": 1, "Start": 2048}}}]}`,
+			"Partition /dev/sda1 has invalid start 2048 and end 0"},
+		{`{"Version": 2, "System": {}} {}`, "data after the model"},
+		{`null`, "no object"},
+	}
+	for _, test := range tests {
+		fileName := filepath.Join(dir, "system.model")
+		assert.NoError(t, ioutil.WriteFile(fileName, []byte(test.content), 0600))
+		system, err := NewSystemFromJSON(fileName)
+		assert.Nil(t, system, test.content)
+		if assert.Error(t, err, test.content) {
+			assert.Contains(t, err.Error(), test.err, test.content)
+		}
+	}
+
+	_, err = NewSystemFromJSON(filepath.Join(dir, "missing.model"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/sysfs"
+)
+
+// NewSystemFromSysfs - discovers the system from sysfs and procfs below root without external tools and root
+// privileges. Partition tables and superblocks are read if the devices are readable. Failures to read device mapper and
+// md devices are returned as commands.DiscoveryErrors together with the partially discovered system
+func NewSystemFromSysfs(root string) (*System, error) {
+	discovery, err := Discover(context.Background(), SysfsProviders(root), false)
+	return discovery.System, err
+}
+
+// SysfsProviders - providers of the sysfs backend which reads sysfs and procfs below root
+func SysfsProviders(root string) []Provider {
+	reader := sysfs.NewReader(root)
+	return []Provider{
+		&sysfsProvider{provider: provider{"sysfs", PriorityInventory, 0}, reader: reader},
+		&mountTableProvider{provider: provider{"mountinfo", 0, 0}, read: func(context.Context) (*sysfs.MountTable, error) {
+			return reader.MountTable()
+		}},
+		&partitionTableProvider{provider: provider{"partitiontable", PriorityPartitionTable, 1}, root: reader.Root},
+		&dmProvider{provider: provider{"dm", 0, 1}, reader: reader},
+		&mdstatProvider{provider: provider{"mdstat", 0, 1}, reader: reader},
+		&superblockProvider{provider: provider{"superblock", PrioritySuperblock, 2}, root: reader.Root},
+		&btrfsProvider{provider: provider{"btrfs", 0, 2}},
+	}
+}
+
+// sysfsProvider - disks and partitions of sysfs. Hardware partitions of eMMC devices are no disks
+type sysfsProvider struct {
+	provider
+	reader *sysfs.Reader
+	disks  *sysfs.Disks
+}
+
+// Collect -
+func (p *sysfsProvider) Collect(ctx context.Context, d *Discovery) (err error) {
+	p.disks, err = p.reader.NewDisks()
+	return err
+}
+
+// Contribute -
+func (p *sysfsProvider) Contribute(d *Discovery) {
+	if p.disks == nil {
+		return
+	}
+	for _, disk := range p.disks.Disks {
+		if name, err := commands.ParseDeviceName(disk.Name); err == nil && name.IsHardwarePartition() {
+			continue
+		}
+		d.AddDisk(p, disk.Name, Disk{Size: fmt.Sprintf("%dB", disk.Size), Model: disk.Model,
+			SectorSizeLogical: disk.LogicalBlockSize, SectorSizePhysical: disk.PhysicalBlockSize})
+		d.Devices.Add("/dev/"+disk.Name, disk.Dev, "", "", "")
+
+		for _, partition := range disk.Partitions {
+			d.AddPartition(p, disk.Name, Partition{Name: "/dev/" + partition.Name, Number: partition.Number,
+				Start: partition.Start, End: partition.Start + partition.Size - 1, Size: partition.Size})
+			d.Devices.Add("/dev/"+partition.Name, partition.Dev, "", "", "")
+		}
+	}
+}
+
+func (p *sysfsProvider) String() string {
+	if p.disks == nil {
+		return ""
+	}
+	return p.disks.String()
+}
@@ -0,0 +1,196 @@
+package model
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/sysfs"
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// sysroot - copy of the sysroot testData/name of package sysfs. The devices contain the first sectors only and are
+// extended to the size reported by sysfs, otherwise the partitions end beyond the device
+func sysroot(t *testing.T, name string) (string, func()) {
+
+	dir, err := ioutil.TempDir("", "raspiBackupTest")
+	assert.NoError(t, err)
+	source := filepath.Join("../sysfs/testData", name)
+
+	assert.NoError(t, filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, strings.TrimPrefix(path, source))
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	}))
+
+	devices, err := filepath.Glob(filepath.Join(dir, "dev", "*"))
+	assert.NoError(t, err)
+	for _, device := range devices {
+		size, err := ioutil.ReadFile(filepath.Join(dir, "sys/block", filepath.Base(device), "size"))
+		if err != nil { // no disk
+			continue
+		}
+		sectors, err := strconv.ParseInt(strings.TrimSpace(string(size)), 10, 64)
+		assert.NoError(t, err)
+		assert.NoError(t, os.Truncate(device, sectors*512))
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestNewSystemFromSysfs(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	root, cleanup := sysroot(t, "raspifix")
+	defer cleanup()
+	system, err := NewSystemFromSysfs(root)
+	assert.NoError(t, err)
+	assert.Len(t, system.Disks, 2)
+
+	mmc := system.Disks[0]
+	assert.Equal(t, "mmcblk0", mmc.Name)
+	assert.Equal(t, "15931539456B", mmc.Size)
+	assert.Equal(t, "/dev/mmcblk0p1", mmc.Partitions[1].Name)
+	assert.Equal(t, int64(4194304), mmc.Partitions[1].Start)
+	assert.Equal(t, int64(536870911), mmc.Partitions[1].End)
+
+	// partition table is read from dev/mmcblk0
+	assert.Equal(t, "msdos", mmc.PartitionTableType)
+	assert.Equal(t, "2c3ce5a2-02", mmc.Partitions[2].Partuuid)
+	assert.Equal(t, "boot", mmc.Partitions[1].Flags)
+	assert.Equal(t, "", system.Disks[1].PartitionTableType)
+
+	assert.Equal(t, "/dev/mmcblk0p1", system.Bootpartition.DeviceName)
+	assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+}
+
+func TestNewDiskFromImage(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	image, err := ioutil.TempFile("", "raspiBackup*.img")
+	assert.NoError(t, err)
+	defer os.Remove(image.Name())
+
+	mbr, err := ioutil.ReadFile("../sysfs/testData/raspifix/dev/mmcblk0")
+	assert.NoError(t, err)
+	_, err = image.Write(mbr)
+	assert.NoError(t, err)
+	superblock := make([]byte, 1024) // ext4 superblock of partition 2
+	binary.LittleEndian.PutUint32(superblock[0x18:], 2)
+	binary.LittleEndian.PutUint16(superblock[0x38:], 0xef53)
+	binary.LittleEndian.PutUint32(superblock[0x5c:], 0x4)
+	binary.LittleEndian.PutUint32(superblock[0x60:], 0x2|0x40)
+	copy(superblock[0x68:], []byte{0x64, 0xa5, 0xe8, 0x6f, 0x5e, 0xd3, 0x4c, 0x9f, 0xaa, 0xb3, 0xc4, 0xae, 0x24, 0xbf, 0xf9, 0x5a})
+	copy(superblock[0x78:], "rootfs")
+	_, err = image.WriteAt(superblock, 1048576*512+1024)
+	assert.NoError(t, err)
+	assert.NoError(t, image.Truncate(15931539456))
+	image.Close()
+
+	disk, err := NewDiskFromImage(image.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "15931539456B", disk.Size)
+	assert.Equal(t, "msdos", disk.PartitionTableType)
+	assert.Len(t, disk.Partitions, 2)
+	assert.Equal(t, image.Name()+"2", disk.Partitions[2].Name)
+	assert.Equal(t, int64(1048576*512), disk.Partitions[2].Start)
+	assert.Equal(t, "primary", disk.Partitions[2].Kind)
+	assert.Equal(t, "ext4", disk.Partitions[2].FileSystem)
+	assert.Equal(t, "64a5e86f-5ed3-4c9f-aab3-c4ae24bff95a", disk.Partitions[2].Uuid)
+	assert.Equal(t, "rootfs", disk.Partitions[2].Label)
+	assert.Empty(t, disk.Partitions[1].Uuid)
+
+	_, err = NewDiskFromImage("../sysfs/testData/raspifix/proc/partitions")
+	assert.Error(t, err)
+}
+
+func TestNewSystemFromSysfsLuks(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	root, cleanup := sysroot(t, "luks")
+	defer cleanup()
+	system, err := NewSystemFromSysfs(root)
+	assert.NoError(t, err)
+	assert.Len(t, system.Disks, 1)
+
+	if assert.Len(t, system.CryptDevices, 1) {
+		crypt := system.CryptDevices[0]
+		assert.Equal(t, "/dev/mapper/cryptroot", crypt.Device)
+		assert.Equal(t, "/dev/mmcblk0p2", crypt.Container)
+		assert.Equal(t, "LUKS2", crypt.Type)
+		assert.Equal(t, "ext4", crypt.FileSystem)
+		assert.Equal(t, "rootfs", crypt.Label)
+		assert.Equal(t, "/", crypt.Mountpoint)
+	}
+
+	container := system.Disks[0].Partitions[2]
+	assert.Equal(t, "msdos", system.Disks[0].PartitionTableType)
+	assert.Equal(t, "crypto_LUKS", container.FileSystem)
+	assert.Equal(t, "/dev/mapper/cryptroot", container.Mapper)
+	assert.Equal(t, "/dev/mmcblk0p2", system.Rootpartition.DeviceName)
+}
+
+func TestNewSystemFromSysfsRaid(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	system, err := NewSystemFromSysfs("../sysfs/testData/raid")
+	assert.NoError(t, err)
+	assert.Len(t, system.Disks, 3)
+
+	if assert.Len(t, system.RaidArrays, 1) {
+		array := system.RaidArrays[0]
+		assert.Equal(t, "/dev/md0", array.Device)
+		assert.False(t, array.Degraded)
+		assert.Equal(t, "ext4", array.FileSystem)
+		assert.Equal(t, "nas", array.Label)
+		assert.Equal(t, "/srv/nas", array.Mountpoint)
+		assert.Len(t, array.Members, 2)
+	}
+	for _, d := range system.Disks[1:] {
+		assert.Equal(t, "/dev/md0", d.Partitions[1].RaidArray)
+	}
+}
+
+func TestNewBtrfsFilesystemsFromMounts(t *testing.T) {
+
+	mounts, err := sysfs.ParseMountinfo(strings.NewReader(`22 1 0:27 /@ / rw,noatime shared:1 - btrfs /dev/mmcblk0p2 rw,ssd,space_cache=v2,subvolid=256,subvol=/@
+30 22 179:1 / /boot/firmware rw,relatime shared:14 - vfat /dev/mmcblk0p1 rw
+33 22 0:27 /@home /home rw,noatime shared:2 - btrfs /dev/mmcblk0p2 rw,ssd,space_cache=v2,subvolid=257,subvol=/@home
+34 22 0:27 /@home/pi /mnt/pi rw,noatime shared:3 - btrfs /dev/mmcblk0p2 rw,ssd,space_cache=v2,subvolid=257,subvol=/@home
+`))
+	assert.NoError(t, err)
+
+	filesystems := newBtrfsFilesystemsFromMounts(sysfs.NewMountTable(mounts), sysfs.NewDevices())
+	if assert.Len(t, filesystems, 1) {
+		fs := filesystems[0]
+		assert.Equal(t, "/dev/mmcblk0p2", fs.Device)
+		assert.Len(t, fs.Subvolumes, 2)
+		assert.Equal(t, "@", fs.Subvolume(256).Path)
+		assert.Equal(t, []string{"/home"}, fs.Subvolume(257).Mountpoints)
+	}
+}
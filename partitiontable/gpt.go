@@ -0,0 +1,163 @@
+package partitiontable
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+const (
+	gptSignature      = "EFI PART"
+	gptMinHeaderSize  = 92
+	gptMaxEntries     = 1024
+	gptMinEntrySize   = 128
+	gptMaxEntriesSize = 1 << 20 // e.g. 1024 entries of 1 KiB
+	gptNameSize       = 72
+)
+
+// ErrInvalidGPT - neither the primary nor the backup gpt is valid
+var ErrInvalidGPT = errors.New("Primary and backup GPT are invalid")
+
+type gptHeader struct {
+	currentLBA   uint64
+	backupLBA    uint64
+	lastUsable   uint64
+	diskGUID     string
+	entriesLBA   uint64
+	entries      uint32
+	entrySize    uint32
+	entriesCRC32 uint32
+}
+
+// readGPTHeader - reads and validates the header and its entries at lba. size is the size of the device in bytes or -1
+// if unknown
+func readGPTHeader(r io.ReaderAt, lba int64, sectorSize int, size int64) (*gptHeader, []*Partition, error) {
+
+	b := make([]byte, sectorSize)
+	if _, err := r.ReadAt(b, lba*int64(sectorSize)); err != nil {
+		return nil, nil, err
+	}
+	if string(b[:8]) != gptSignature {
+		return nil, nil, fmt.Errorf("No GPT signature at sector %d", lba)
+	}
+
+	headerSize := binary.LittleEndian.Uint32(b[12:])
+	if headerSize < gptMinHeaderSize || int(headerSize) > sectorSize {
+		return nil, nil, fmt.Errorf("Invalid GPT header size %d at sector %d", headerSize, lba)
+	}
+	crc := binary.LittleEndian.Uint32(b[16:])
+	header := append([]byte{}, b[:headerSize]...)
+	binary.LittleEndian.PutUint32(header[16:], 0)
+	if crc32.ChecksumIEEE(header) != crc {
+		return nil, nil, fmt.Errorf("Invalid GPT header crc at sector %d", lba)
+	}
+
+	h := &gptHeader{currentLBA: binary.LittleEndian.Uint64(b[24:]), backupLBA: binary.LittleEndian.Uint64(b[32:]),
+		lastUsable: binary.LittleEndian.Uint64(b[48:]), diskGUID: guid(b[56:72]), entriesLBA: binary.LittleEndian.Uint64(b[72:]),
+		entries: binary.LittleEndian.Uint32(b[80:]), entrySize: binary.LittleEndian.Uint32(b[84:]), entriesCRC32: binary.LittleEndian.Uint32(b[88:])}
+	// entries are 128 * 2^n bytes. Corrupt or hostile headers must not allocate huge entry arrays
+	if h.entries > gptMaxEntries || h.entrySize < gptMinEntrySize || h.entrySize&(h.entrySize-1) != 0 ||
+		uint64(h.entries)*uint64(h.entrySize) > gptMaxEntriesSize {
+		return nil, nil, fmt.Errorf("Invalid GPT entries %d of size %d at sector %d", h.entries, h.entrySize, lba)
+	}
+	lastLBA := h.lastUsable
+	if size > 0 && uint64(size/int64(sectorSize)-1) < lastLBA {
+		lastLBA = uint64(size/int64(sectorSize) - 1)
+	}
+
+	entries := make([]byte, int(h.entries)*int(h.entrySize))
+	if _, err := r.ReadAt(entries, int64(h.entriesLBA)*int64(sectorSize)); err != nil {
+		return nil, nil, err
+	}
+	if crc32.ChecksumIEEE(entries) != h.entriesCRC32 {
+		return nil, nil, fmt.Errorf("Invalid GPT entries crc of header at sector %d", lba)
+	}
+
+	var partitions []*Partition
+	for i := 0; i < int(h.entries); i++ {
+		e := entries[i*int(h.entrySize):]
+		typeGUID := guid(e[0:16])
+		if typeGUID == "00000000-0000-0000-0000-000000000000" {
+			continue
+		}
+		first, last := binary.LittleEndian.Uint64(e[32:]), binary.LittleEndian.Uint64(e[40:])
+		if last < first || last > lastLBA {
+			return nil, nil, fmt.Errorf("Invalid GPT entry %d with first sector %d and last sector %d at sector %d", i+1, first, last, lba)
+		}
+		partitions = append(partitions, &Partition{Number: i + 1, Start: int64(first) * int64(sectorSize), Size: int64(last-first+1) * int64(sectorSize),
+			Kind: KindPrimary, TypeGUID: typeGUID, Partuuid: guid(e[16:32]), Attributes: binary.LittleEndian.Uint64(e[48:]),
+			Name: name(e[56 : 56+gptNameSize])})
+	}
+	return h, partitions, nil
+}
+
+// readGPT - reads the primary gpt and validates the backup gpt. The backup is used if the primary gpt is corrupt.
+// size is the size of the device in bytes or -1 if unknown
+func readGPT(r io.ReaderAt, sectorSize int, size int64) (*Table, error) {
+
+	table := &Table{Type: TypeGPT, SectorSize: sectorSize}
+
+	primary, partitions, err := readGPTHeader(r, 1, sectorSize, size)
+	table.PrimaryValid = err == nil
+	if err != nil {
+		tools.Logger.Debugf("Primary GPT: %s", err.Error())
+	}
+
+	backupLBA := size/int64(sectorSize) - 1
+	if primary != nil {
+		backupLBA = int64(primary.backupLBA)
+	}
+
+	var backup *gptHeader
+	var backupPartitions []*Partition
+	if backupLBA > 1 {
+		backup, backupPartitions, err = readGPTHeader(r, backupLBA, sectorSize, size)
+		table.BackupValid = err == nil
+		if err != nil {
+			tools.Logger.Debugf("Backup GPT: %s", err.Error())
+		}
+	}
+
+	switch {
+	case primary != nil:
+		table.DiskGUID, table.Partitions = primary.diskGUID, partitions
+	case backup != nil:
+		table.DiskGUID, table.Partitions = backup.diskGUID, backupPartitions
+	default:
+		return nil, ErrInvalidGPT
+	}
+	return table, nil
+}
+
+// guid - mixed endian GUID, the first three fields are little endian
+func guid(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(b[0:4]), binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]), b[8:10], b[10:16])
+}
+
+// name - UTF-16LE partition name terminated by 0
+func name(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return strings.TrimSpace(string(utf16.Decode(u)))
+}
@@ -0,0 +1,176 @@
+package partitiontable
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+const (
+	mbrSize            = 512
+	mbrSignatureOffset = 510
+	mbrDiskSignature   = 440
+	mbrEntries         = 446
+	mbrEntrySize       = 16
+	maxLogical         = 128 // protection against loops in the ebr chain
+
+	typeProtective = 0xee
+)
+
+// extendedTypes - msdos partition types of extended partitions
+var extendedTypes = map[byte]bool{0x05: true, 0x0f: true, 0x85: true}
+
+type mbrEntry struct {
+	status  byte
+	ptype   byte
+	start   uint32 // lba
+	sectors uint32
+}
+
+// valid - the entry is empty or a partition between the sectors first and end. end is -1 if unknown. base is the sector
+// the start of the entry is relative to. Boot sectors of filesystems, e.g. of a FAT superfloppy or of NTFS, also end
+// with 0x55aa but contain boot code instead of entries
+func (e mbrEntry) valid(base, first, end int64) bool {
+	if e.status != 0x00 && e.status != 0x80 {
+		return false
+	}
+	if e.ptype == 0 || e.sectors == 0 {
+		return true
+	}
+	start := base + int64(e.start)
+	return start >= first && (end < 0 || start+int64(e.sectors) <= end)
+}
+
+type mbr struct {
+	signature uint32
+	entries   [4]mbrEntry
+}
+
+// readSector - reads the mbr or an ebr
+func readSector(r io.ReaderAt, offset int64) (*mbr, error) {
+
+	b := make([]byte, mbrSize)
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return nil, err
+	}
+	if b[mbrSignatureOffset] != 0x55 || b[mbrSignatureOffset+1] != 0xaa {
+		return nil, ErrNoPartitionTable
+	}
+
+	m := &mbr{signature: binary.LittleEndian.Uint32(b[mbrDiskSignature:])}
+	for i := range m.entries {
+		e := b[mbrEntries+i*mbrEntrySize:]
+		m.entries[i] = mbrEntry{status: e[0], ptype: e[4], start: binary.LittleEndian.Uint32(e[8:]), sectors: binary.LittleEndian.Uint32(e[12:])}
+	}
+	return m, nil
+}
+
+func readMBR(r io.ReaderAt) (*mbr, error) {
+	return readSector(r, 0)
+}
+
+// protective - the mbr protects a gpt
+func (m *mbr) protective() bool {
+	for _, e := range m.entries {
+		if e.ptype == typeProtective {
+			return true
+		}
+	}
+	return false
+}
+
+// hybrid - the protective mbr also contains partitions, e.g. for the Raspberry Pi firmware which boots from msdos only
+func (m *mbr) hybrid() bool {
+	for _, e := range m.entries {
+		if e.ptype != 0 && e.ptype != typeProtective {
+			return true
+		}
+	}
+	return false
+}
+
+// table - primary partitions and the logical partitions of the ebr chain
+func (m *mbr) table(r io.ReaderAt, sectorSize int) (*Table, error) {
+
+	table := &Table{Type: TypeMsdos, SectorSize: sectorSize, DiskSignature: m.signature}
+	ss := int64(sectorSize)
+
+	end := int64(-1)
+	if n := size(r); n > 0 {
+		end = n / ss
+	}
+	for i, e := range m.entries {
+		if !e.valid(0, 1, end) {
+			tools.Logger.Debugf("Invalid msdos entry %d with status 0x%x start %d and %d sectors", i+1, e.status, e.start, e.sectors)
+			return nil, ErrNoPartitionTable
+		}
+	}
+
+	for i, e := range m.entries {
+		if e.ptype == 0 || e.sectors == 0 {
+			continue
+		}
+		p := m.partition(i+1, e, 0, ss)
+		table.Partitions = append(table.Partitions, p)
+
+		if !extendedTypes[e.ptype] {
+			continue
+		}
+		p.Kind = KindExtended
+		logicals, err := m.logicals(r, int64(e.start), int64(e.start)+int64(e.sectors), ss)
+		if err != nil {
+			return nil, err
+		}
+		table.Partitions = append(table.Partitions, logicals...)
+	}
+	return table, nil
+}
+
+// logicals - follows the ebr chain. The first entry of an ebr is relative to the ebr, the second entry links the
+// next ebr relative to the start of the extended partition. Logical partitions and ebrs have to be inside the extended
+// partition which ends before the sector extendedEnd
+func (m *mbr) logicals(r io.ReaderAt, extendedStart, extendedEnd int64, ss int64) ([]*Partition, error) {
+
+	var result []*Partition
+	ebr := extendedStart
+	visited := make(map[int64]bool)
+
+	for number := 5; number < 5+maxLogical && !visited[ebr]; number++ {
+		visited[ebr] = true
+		sector, err := readSector(r, ebr*ss)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid extended boot record at sector %d: %s", ebr, err.Error())
+		}
+		if !sector.entries[0].valid(ebr, ebr+1, extendedEnd) || !sector.entries[1].valid(extendedStart, extendedStart+1, extendedEnd) {
+			tools.Logger.Debugf("Invalid extended boot record at sector %d of extended partition at sectors %d-%d",
+				ebr, extendedStart, extendedEnd-1)
+			return nil, ErrNoPartitionTable
+		}
+		if e := sector.entries[0]; e.ptype != 0 && e.sectors != 0 {
+			p := m.partition(number, e, ebr, ss)
+			p.Kind = KindLogical
+			result = append(result, p)
+		}
+		next := sector.entries[1]
+		if next.ptype == 0 || next.start == 0 {
+			break
+		}
+		ebr = extendedStart + int64(next.start)
+	}
+	return result, nil
+}
+
+func (m *mbr) partition(number int, e mbrEntry, base int64, ss int64) *Partition {
+	return &Partition{Number: number, Start: (base + int64(e.start)) * ss, Size: int64(e.sectors) * ss, Kind: KindPrimary,
+		Type: fmt.Sprintf("0x%x", e.ptype), Partuuid: fmt.Sprintf("%08x-%02x", m.signature, number), Bootable: e.status == 0x80}
+}
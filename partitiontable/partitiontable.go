@@ -0,0 +1,163 @@
+package partitiontable
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Partition table types, same as reported by parted
+const (
+	TypeMsdos = "msdos"
+	TypeGPT   = "gpt"
+)
+
+// Partition kinds
+const (
+	KindPrimary  = "primary"
+	KindExtended = "extended" // msdos container of logical partitions
+	KindLogical  = "logical"  // msdos partition 5 and higher
+)
+
+// DefaultSectorSize - sector size of images and of most devices
+const DefaultSectorSize = 512
+
+// ErrNoPartitionTable - the device or image has no msdos or gpt partition table
+var ErrNoPartitionTable = errors.New("No partition table found")
+
+// Partition -
+type Partition struct {
+	Number     int
+	Start      int64  // bytes
+	Size       int64  // bytes
+	Kind       string // primary, extended or logical
+	Type       string // msdos partition type, e.g. 0xc
+	TypeGUID   string // gpt partition type
+	Partuuid   string // 2c3ce5a2-02 for msdos, unique partition GUID for gpt
+	Name       string // gpt only
+	Bootable   bool   // msdos only
+	Attributes uint64 // gpt only
+}
+
+// End - last byte of the partition
+func (p Partition) End() int64 {
+	return p.Start + p.Size - 1
+}
+
+func (p Partition) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("Partition: %d Start: %d End: %d Size: %d Kind: %s Partuuid: %s", p.Number, p.Start, p.End(), p.Size, p.Kind, p.Partuuid))
+	for _, kv := range [][2]string{{"Type", p.Type}, {"TypeGUID", p.TypeGUID}, {"Name", p.Name}} {
+		if kv[1] != "" {
+			result.WriteString(fmt.Sprintf(" %s: %s", kv[0], kv[1]))
+		}
+	}
+	if p.Bootable {
+		result.WriteString(" Bootable")
+	}
+	return result.String()
+}
+
+// Table - partition table of a device or image
+type Table struct {
+	Type          string // msdos or gpt
+	SectorSize    int
+	DiskSignature uint32 // msdos, used as prefix of the partuuids on Raspberry Pi OS
+	DiskGUID      string // gpt
+	Hybrid        bool   // gpt with a hybrid instead of a protective mbr
+	PrimaryValid  bool   // gpt: primary header and entries have a valid crc
+	BackupValid   bool   // gpt: backup header and entries have a valid crc
+	Partitions    []*Partition
+}
+
+func (t Table) String() string {
+	var result bytes.Buffer
+	switch t.Type {
+	case TypeGPT:
+		result.WriteString(fmt.Sprintf("Table: %s Sector size: %d DiskGUID: %s Primary valid: %t Backup valid: %t Hybrid: %t\n",
+			t.Type, t.SectorSize, t.DiskGUID, t.PrimaryValid, t.BackupValid, t.Hybrid))
+	default:
+		result.WriteString(fmt.Sprintf("Table: %s Sector size: %d DiskSignature: %08x\n", t.Type, t.SectorSize, t.DiskSignature))
+	}
+	for _, p := range t.Partitions {
+		result.WriteString(fmt.Sprintf("%s\n", p))
+	}
+	return result.String()
+}
+
+// Partition - partition with the number or nil
+func (t Table) Partition(number int) *Partition {
+	for _, p := range t.Partitions {
+		if p.Number == number {
+			return p
+		}
+	}
+	return nil
+}
+
+// Read - reads the partition table of a device or image. sectorSize is the logical sector size of the device,
+// 0 for DefaultSectorSize
+func Read(r io.ReaderAt, sectorSize int) (*Table, error) {
+
+	if sectorSize == 0 {
+		sectorSize = DefaultSectorSize
+	}
+
+	mbr, err := readMBR(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var table *Table
+	if mbr.protective() {
+		table, err = readGPT(r, sectorSize, size(r))
+		if err != nil {
+			return nil, err
+		}
+		table.Hybrid = mbr.hybrid()
+	} else {
+		table, err = mbr.table(r, sectorSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(table.Partitions, func(i, j int) bool {
+		return table.Partitions[i].Number < table.Partitions[j].Number
+	})
+	return table, nil
+}
+
+// ReadFile - reads the partition table of a device, e.g. /dev/sda, or an image file
+func ReadFile(fileName string, sectorSize int) (*Table, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, sectorSize)
+}
+
+// size - size of the device or image, -1 if unknown. Block devices report size 0 with stat so the end is sought
+func size(r io.ReaderAt) int64 {
+	switch s := r.(type) {
+	case interface{ Size() int64 }:
+		return s.Size()
+	case io.Seeker:
+		if n, err := s.Seek(0, io.SeekEnd); err == nil {
+			return n
+		}
+	}
+	return -1
+}
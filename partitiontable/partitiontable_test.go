@@ -0,0 +1,334 @@
+package partitiontable
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// sparseImage - image with only the written sectors in memory, all other sectors are zero
+type sparseImage struct {
+	size    int64
+	sectors map[int64][]byte
+}
+
+func newSparseImage(size int64) *sparseImage {
+	return &sparseImage{size: size, sectors: make(map[int64][]byte)}
+}
+
+func (s *sparseImage) Size() int64 {
+	return s.size
+}
+
+func (s *sparseImage) ReadAt(b []byte, off int64) (int, error) {
+	for i := range b {
+		sector, offset := (off+int64(i))/512, (off+int64(i))%512
+		b[i] = 0
+		if data, ok := s.sectors[sector]; ok {
+			b[i] = data[offset]
+		}
+	}
+	return len(b), nil
+}
+
+func (s *sparseImage) write(off int64, b []byte) {
+	for i := range b {
+		sector, offset := (off+int64(i))/512, (off+int64(i))%512
+		if _, ok := s.sectors[sector]; !ok {
+			s.sectors[sector] = make([]byte, 512)
+		}
+		s.sectors[sector][offset] = b[i]
+	}
+}
+
+// writeMBR - writes a mbr or ebr with up to four entries of type, start and sectors
+func (s *sparseImage) writeMBR(lba int64, signature uint32, entries ...[3]uint32) {
+	b := make([]byte, 512)
+	binary.LittleEndian.PutUint32(b[440:], signature)
+	for i, e := range entries {
+		entry := b[446+i*16:]
+		entry[4] = byte(e[0])
+		binary.LittleEndian.PutUint32(entry[8:], e[1])
+		binary.LittleEndian.PutUint32(entry[12:], e[2])
+	}
+	b[510], b[511] = 0x55, 0xaa
+	s.write(lba*512, b)
+}
+
+func encodeGUID(g string) []byte {
+	b, _ := hex.DecodeString(strings.Replace(g, "-", "", -1))
+	binary.LittleEndian.PutUint32(b[0:], binary.BigEndian.Uint32(b[0:]))
+	binary.LittleEndian.PutUint16(b[4:], binary.BigEndian.Uint16(b[4:]))
+	binary.LittleEndian.PutUint16(b[6:], binary.BigEndian.Uint16(b[6:]))
+	return b
+}
+
+type gptEntry struct {
+	typeGUID, partGUID string
+	first, last        uint64
+	name               string
+}
+
+// writeGPT - writes the primary and backup gpt with 128 entries
+func (s *sparseImage) writeGPT(diskGUID string, entries ...gptEntry) {
+
+	last := uint64(s.size/512 - 1)
+	table := make([]byte, 128*128)
+	for i, e := range entries {
+		entry := table[i*128:]
+		copy(entry[0:], encodeGUID(e.typeGUID))
+		copy(entry[16:], encodeGUID(e.partGUID))
+		binary.LittleEndian.PutUint64(entry[32:], e.first)
+		binary.LittleEndian.PutUint64(entry[40:], e.last)
+		for j, c := range utf16.Encode([]rune(e.name)) {
+			binary.LittleEndian.PutUint16(entry[56+2*j:], c)
+		}
+	}
+
+	header := func(current, backup, entriesLBA uint64) []byte {
+		h := make([]byte, 92)
+		copy(h, gptSignature)
+		binary.LittleEndian.PutUint32(h[8:], 0x00010000)
+		binary.LittleEndian.PutUint32(h[12:], 92)
+		binary.LittleEndian.PutUint64(h[24:], current)
+		binary.LittleEndian.PutUint64(h[32:], backup)
+		binary.LittleEndian.PutUint64(h[40:], 34)
+		binary.LittleEndian.PutUint64(h[48:], last-33)
+		copy(h[56:], encodeGUID(diskGUID))
+		binary.LittleEndian.PutUint64(h[72:], entriesLBA)
+		binary.LittleEndian.PutUint32(h[80:], 128)
+		binary.LittleEndian.PutUint32(h[84:], 128)
+		binary.LittleEndian.PutUint32(h[88:], crc32.ChecksumIEEE(table))
+		binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+		return h
+	}
+
+	s.write(1*512, header(1, last, 2))
+	s.write(2*512, table)
+	s.write(int64(last-32)*512, table)
+	s.write(int64(last)*512, header(last, 1, last-32))
+}
+
+// patchGPTHeader - changes the gpt header at lba and updates its crc
+func (s *sparseImage) patchGPTHeader(lba int64, patch func(h []byte)) {
+	h := s.sectors[lba]
+	patch(h)
+	binary.LittleEndian.PutUint32(h[16:], 0)
+	binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h[:92]))
+}
+
+const (
+	linuxData = "0fc63daf-8483-4772-8e79-3d69d8477de4"
+	efiSystem = "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"
+	diskGUID  = "5e4d3c2b-1a09-4f8e-b7d6-c5b4a3928170"
+)
+
+func gptImage() *sparseImage {
+	image := newSparseImage(1 << 30)
+	image.writeMBR(0, 0, [3]uint32{typeProtective, 1, 1<<21 - 1})
+	image.writeGPT(diskGUID,
+		gptEntry{efiSystem, "7c1a9d42-3e5b-4f6a-8b2c-1d0e9f8a7b6c", 2048, 1050623, "EFI System Partition"},
+		gptEntry{linuxData, "3f2e1d0c-9b8a-4765-a4b3-c2d1e0f9a8b7", 1050624, 2097118, "rootfs"})
+	return image
+}
+
+func TestMsdos(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	image := newSparseImage(1 << 30)
+	image.writeMBR(0, 0x2c3ce5a2, [3]uint32{0x0c, 8192, 524288}, [3]uint32{0x05, 532480, 1000000})
+	image.writeMBR(532480, 0, [3]uint32{0x83, 2048, 200000}, [3]uint32{0x05, 204800, 102048})
+	image.writeMBR(532480+204800, 0, [3]uint32{0x83, 2048, 100000})
+
+	table, err := Read(image, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeMsdos, table.Type)
+	assert.Equal(t, uint32(0x2c3ce5a2), table.DiskSignature)
+	assert.Len(t, table.Partitions, 4)
+
+	assert.Equal(t, KindPrimary, table.Partition(1).Kind)
+	assert.Equal(t, "0xc", table.Partition(1).Type)
+	assert.Equal(t, int64(8192*512), table.Partition(1).Start)
+	assert.Equal(t, "2c3ce5a2-01", table.Partition(1).Partuuid)
+	assert.Equal(t, KindExtended, table.Partition(2).Kind)
+
+	p5, p6 := table.Partition(5), table.Partition(6)
+	assert.Equal(t, KindLogical, p5.Kind)
+	assert.Equal(t, int64((532480+2048)*512), p5.Start)
+	assert.Equal(t, int64(200000*512), p5.Size)
+	assert.Equal(t, int64((532480+204800+2048)*512), p6.Start)
+	assert.Equal(t, "2c3ce5a2-06", p6.Partuuid)
+}
+
+// fatBootSector - boot sector of a FAT16 superfloppy, i.e. a filesystem on the whole disk. The boot message overlaps
+// the partition entries of a mbr
+func fatBootSector() []byte {
+	b := make([]byte, 512)
+	b[0], b[1], b[2] = 0xeb, 0x3c, 0x90
+	copy(b[3:], "MSDOS5.0")
+	binary.LittleEndian.PutUint16(b[11:], 512)
+	b[13], b[16], b[21] = 4, 2, 0xf8
+	copy(b[0x2b:], "NO NAME    FAT16   ")
+	for i := 0x3e; i < 510; {
+		i += copy(b[i:510], "\r\nNon-system disk or disk error\r\nReplace and press any key when ready\r\n")
+	}
+	b[510], b[511] = 0x55, 0xaa
+	return b
+}
+
+func TestMsdosInvalid(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	image := newSparseImage(1 << 30)
+	image.write(0, fatBootSector())
+	_, err := Read(image, 0)
+	assert.Equal(t, ErrNoPartitionTable, err)
+
+	// invalid status
+	image = newSparseImage(1 << 30)
+	image.writeMBR(0, 0x2c3ce5a2, [3]uint32{0x0c, 8192, 524288})
+	image.sectors[0][446] = 0x12
+	_, err = Read(image, 0)
+	assert.Equal(t, ErrNoPartitionTable, err)
+
+	// beyond the disk
+	image = newSparseImage(1 << 30)
+	image.writeMBR(0, 0x2c3ce5a2, [3]uint32{0x0c, 8192, 524288}, [3]uint32{0x83, 532480, 2097152})
+	_, err = Read(image, 0)
+	assert.Equal(t, ErrNoPartitionTable, err)
+
+	// logical partition beyond the extended partition
+	image = newSparseImage(1 << 30)
+	image.writeMBR(0, 0x2c3ce5a2, [3]uint32{0x0c, 8192, 524288}, [3]uint32{0x05, 532480, 100000})
+	image.writeMBR(532480, 0, [3]uint32{0x83, 2048, 200000})
+	_, err = Read(image, 0)
+	assert.Equal(t, ErrNoPartitionTable, err)
+
+	// next ebr beyond the extended partition
+	image.writeMBR(532480, 0, [3]uint32{0x83, 2048, 50000}, [3]uint32{0x05, 204800, 102048})
+	_, err = Read(image, 0)
+	assert.Equal(t, ErrNoPartitionTable, err)
+
+	// partitions of the whole disk are valid
+	image = newSparseImage(1 << 30)
+	image.writeMBR(0, 0x2c3ce5a2, [3]uint32{0x83, 1, 1<<21 - 1})
+	table, err := Read(image, 0)
+	assert.NoError(t, err)
+	assert.Len(t, table.Partitions, 1)
+}
+
+func TestGPT(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	table, err := Read(gptImage(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeGPT, table.Type)
+	assert.Equal(t, diskGUID, table.DiskGUID)
+	assert.True(t, table.PrimaryValid)
+	assert.True(t, table.BackupValid)
+	assert.False(t, table.Hybrid)
+	assert.Len(t, table.Partitions, 2)
+
+	p1 := table.Partition(1)
+	assert.Equal(t, efiSystem, p1.TypeGUID)
+	assert.Equal(t, "7c1a9d42-3e5b-4f6a-8b2c-1d0e9f8a7b6c", p1.Partuuid)
+	assert.Equal(t, "EFI System Partition", p1.Name)
+	assert.Equal(t, int64(2048*512), p1.Start)
+	assert.Equal(t, int64(1050623*512+511), p1.End())
+}
+
+func TestGPTCorrupt(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	// primary header is corrupt, backup is used
+	image := gptImage()
+	image.sectors[1][60] ^= 0xff
+	table, err := Read(image, 0)
+	assert.NoError(t, err)
+	assert.False(t, table.PrimaryValid)
+	assert.True(t, table.BackupValid)
+	assert.Equal(t, diskGUID, table.DiskGUID)
+	assert.Len(t, table.Partitions, 2)
+
+	// primary entries are corrupt
+	image = gptImage()
+	image.sectors[2][0] ^= 0xff
+	table, err = Read(image, 0)
+	assert.NoError(t, err)
+	assert.False(t, table.PrimaryValid)
+	assert.Equal(t, "rootfs", table.Partition(2).Name)
+
+	// both are corrupt
+	image.sectors[image.size/512-1][60] ^= 0xff
+	_, err = Read(image, 0)
+	assert.Equal(t, ErrInvalidGPT, err)
+}
+
+func TestGPTInvalidEntries(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	last := int64(1<<30/512 - 1)
+	for _, patch := range []func(h []byte){
+		func(h []byte) { binary.LittleEndian.PutUint32(h[84:], 0xffffffff) }, // huge entries
+		func(h []byte) { binary.LittleEndian.PutUint32(h[84:], 192) },        // no power of 2
+		func(h []byte) { // 1024 entries of 4 KiB exceed 1 MiB
+			binary.LittleEndian.PutUint32(h[80:], 1024)
+			binary.LittleEndian.PutUint32(h[84:], 4096)
+		},
+	} {
+		image := gptImage()
+		image.patchGPTHeader(1, patch)
+		image.patchGPTHeader(last, patch)
+		_, _, err := readGPTHeader(image, 1, 512, image.size)
+		assert.Error(t, err)
+		_, err = Read(image, 0)
+		assert.Equal(t, ErrInvalidGPT, err)
+	}
+
+	for _, entry := range []gptEntry{
+		{linuxData, "3f2e1d0c-9b8a-4765-a4b3-c2d1e0f9a8b7", 2097118, 1050624, "rootfs"}, // last < first
+		{linuxData, "3f2e1d0c-9b8a-4765-a4b3-c2d1e0f9a8b7", 1050624, 1 << 40, "rootfs"}, // beyond the disk
+	} {
+		image := newSparseImage(1 << 30)
+		image.writeMBR(0, 0, [3]uint32{typeProtective, 1, 1<<21 - 1})
+		image.writeGPT(diskGUID, entry)
+		_, _, err := readGPTHeader(image, 1, 512, image.size)
+		assert.Contains(t, err.Error(), "Invalid GPT entry 1")
+		_, err = Read(image, 0)
+		assert.Equal(t, ErrInvalidGPT, err)
+	}
+}
+
+func TestHybridAndEmpty(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	image := gptImage()
+	image.writeMBR(0, 0, [3]uint32{0x0c, 2048, 1048576}, [3]uint32{typeProtective, 1, 2047})
+	table, err := Read(image, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeGPT, table.Type)
+	assert.True(t, table.Hybrid)
+
+	_, err = Read(newSparseImage(1<<20), 0)
+	assert.Equal(t, ErrNoPartitionTable, err)
+}
@@ -0,0 +1,260 @@
+package preflight
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// Tool - external tool raspiBackup depends on
+type Tool struct {
+	Name        string   // parted
+	VersionArgs []string // --version
+	MinVersion  string   // 2.27, empty if any version is accepted
+	Feature     string   // feature which requires MinVersion, e.g. JSON output
+}
+
+// Mode - set of tools required for an operation
+type Mode string
+
+const (
+	// ModeDiscover - system discovery
+	ModeDiscover Mode = "discover"
+	// ModeRsync - file based backup with rsync
+	ModeRsync Mode = "rsync"
+	// ModeTar - file based backup with tar
+	ModeTar Mode = "tar"
+	// ModeDD - image backup with dd
+	ModeDD Mode = "dd"
+	// ModeSnapshot - file based backup of a btrfs snapshot with rsync or btrfs send
+	ModeSnapshot Mode = "snapshot"
+	// ModeRestoreRsync - restore of a rsync or snapshot backup
+	ModeRestoreRsync Mode = "restore-rsync"
+	// ModeRestoreTar - restore of a tar backup
+	ModeRestoreTar Mode = "restore-tar"
+	// ModeRestoreDD - restore of an image to a device
+	ModeRestoreDD Mode = "restore-dd"
+	// ModeLuks - backup or restore of the LUKS header of an encrypted source or target
+	ModeLuks Mode = "luks"
+)
+
+// Modes - all known modes
+var Modes = []Mode{ModeDiscover, ModeRsync, ModeTar, ModeDD, ModeSnapshot, ModeRestoreRsync, ModeRestoreTar, ModeRestoreDD, ModeLuks}
+
+var (
+	parted     = Tool{Name: "parted", VersionArgs: []string{"--version"}, MinVersion: "3.0", Feature: "machine parseable output"}
+	blkid      = Tool{Name: "blkid", VersionArgs: []string{"-V"}}
+	lsblk      = Tool{Name: "lsblk", VersionArgs: []string{"--version"}, MinVersion: "2.27", Feature: "JSON output"}
+	findmnt    = Tool{Name: "findmnt", VersionArgs: []string{"--version"}, MinVersion: "2.27", Feature: "JSON output"}
+	rsync      = Tool{Name: "rsync", VersionArgs: []string{"--version"}, MinVersion: "2.5.6", Feature: "--link-dest"}
+	tar        = Tool{Name: "tar", VersionArgs: []string{"--version"}}
+	dd         = Tool{Name: "dd", VersionArgs: []string{"--version"}}
+	gzip       = Tool{Name: "gzip", VersionArgs: []string{"--version"}}
+	btrfs      = Tool{Name: "btrfs", VersionArgs: []string{"--version"}, MinVersion: "4.0", Feature: "subvolume list -u -q"}
+	cryptsetup = Tool{Name: "cryptsetup", VersionArgs: []string{"--version"}}
+)
+
+// Requirements - tools required by each mode
+var Requirements = map[Mode][]Tool{
+	ModeDiscover:     {parted, blkid, lsblk, findmnt},
+	ModeRsync:        {rsync},
+	ModeTar:          {tar, gzip},
+	ModeDD:           {dd, gzip},
+	ModeSnapshot:     {btrfs, rsync},
+	ModeRestoreRsync: {rsync},
+	ModeRestoreTar:   {tar, gzip},
+	ModeRestoreDD:    {dd, gzip},
+	ModeLuks:         {cryptsetup},
+}
+
+// LookPath - locates the binary of a tool in the fixed PATH used for all commands
+var LookPath = func(name string) (string, error) {
+	for _, dir := range filepath.SplitList(commands.FixedPath) {
+		candidate := filepath.Join(dir, name)
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+var versionPattern = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// ParseVersion - first dotted version number in the version output of a tool
+func ParseVersion(output string) string {
+	return versionPattern.FindString(output)
+}
+
+// CompareVersions - -1, 0 or 1 if version a is lower, equal or greater than version b
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// ToolReport - result of the check of one tool
+type ToolReport struct {
+	Tool    Tool
+	Path    string // empty if not found
+	Version string // empty if unknown
+	Ok      bool
+	Error   string
+}
+
+func (t ToolReport) String() string {
+	state := "OK"
+	if !t.Ok {
+		state = "FAILED"
+	}
+	version := t.Version
+	if version == "" {
+		version = "unknown"
+	}
+	result := fmt.Sprintf("%-10s %-6s Path: %s Version: %s", t.Tool.Name, state, t.Path, version)
+	if t.Error != "" {
+		result += " - " + t.Error
+	}
+	return result
+}
+
+// Report - result of a preflight check
+type Report struct {
+	Modes []Mode
+	Tools []*ToolReport
+}
+
+// Ok - true if all required tools are available in the required version
+func (r Report) Ok() bool {
+	for _, t := range r.Tools {
+		if !t.Ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Failed - tools which are missing or too old
+func (r Report) Failed() []*ToolReport {
+	var result []*ToolReport
+	for _, t := range r.Tools {
+		if !t.Ok {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func (r Report) String() string {
+	var result bytes.Buffer
+	modes := make([]string, 0, len(r.Modes))
+	for _, m := range r.Modes {
+		modes = append(modes, string(m))
+	}
+	result.WriteString(fmt.Sprintf("Preflight check for %s\n", strings.Join(modes, ", ")))
+	for _, t := range r.Tools {
+		result.WriteString(t.String())
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+// checkTool - locates the tool and detects its version
+func checkTool(ctx context.Context, tool Tool) *ToolReport {
+
+	report := &ToolReport{Tool: tool}
+
+	path, err := LookPath(tool.Name)
+	if err != nil {
+		report.Error = "not installed"
+		return report
+	}
+	report.Path = path
+
+	// some tools report their version on stderr or exit with an error on --help
+	stdout, stderr, err := commands.NewCommandContext(ctx, commands.TypeNormal, path, tool.VersionArgs...).Output()
+	report.Version = ParseVersion(string(stdout) + string(stderr))
+	if report.Version == "" && err != nil {
+		tools.Logger.Debugf("Unable to retrieve version of %s: %s", tool.Name, err.Error())
+	}
+
+	switch {
+	case tool.MinVersion == "":
+		report.Ok = true
+	case report.Version == "":
+		report.Error = fmt.Sprintf("unable to detect version, at least %s required for %s", tool.MinVersion, tool.Feature)
+	case CompareVersions(report.Version, tool.MinVersion) < 0:
+		report.Error = fmt.Sprintf("version %s required for %s", tool.MinVersion, tool.Feature)
+	default:
+		report.Ok = true
+	}
+	return report
+}
+
+// Check - checks all tools required by the modes
+func Check(ctx context.Context, modes ...Mode) *Report {
+
+	report := &Report{Modes: modes}
+	seen := make(map[string]bool)
+	for _, mode := range modes {
+		for _, tool := range Requirements[mode] {
+			if seen[tool.Name] {
+				continue
+			}
+			seen[tool.Name] = true
+			report.Tools = append(report.Tools, checkTool(ctx, tool))
+		}
+	}
+
+	sort.SliceStable(report.Tools, func(i, j int) bool {
+		return report.Tools[i].Tool.Name < report.Tools[j].Tool.Name
+	})
+	return report
+}
+
+// Require - returns an error listing all missing or too old tools required by the modes
+func Require(ctx context.Context, modes ...Mode) error {
+
+	report := Check(ctx, modes...)
+	failed := report.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(failed))
+	for _, t := range failed {
+		messages = append(messages, fmt.Sprintf("%s: %s", t.Tool.Name, t.Error))
+	}
+	return fmt.Errorf("Preflight check failed: %s", strings.Join(messages, ", "))
+}
@@ -0,0 +1,88 @@
+package preflight
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/commands"
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// versionRunner - fake runner which returns the version output of a tool
+type versionRunner map[string][2]string // name -> stdout, stderr
+
+func (r versionRunner) Run(c *commands.Cmd) (*commands.Invocation, error) {
+	output := r[filepath.Base(c.Command)]
+	return &commands.Invocation{Args: c.Args, Stdout: output[0], Stderr: output[1]}, nil
+}
+
+func TestVersions(t *testing.T) {
+
+	assert.Equal(t, "2.38.1", ParseVersion("lsblk from util-linux 2.38.1"))
+	assert.Equal(t, "3.2.7", ParseVersion("rsync  version 3.2.7  protocol version 31"))
+	assert.Equal(t, "1.47.0", ParseVersion("e2fsck 1.47.0 (5-Feb-2023)"))
+	assert.Equal(t, "", ParseVersion("unknown"))
+
+	assert.Equal(t, 0, CompareVersions("2.27", "2.27.0"))
+	assert.Equal(t, -1, CompareVersions("2.9", "2.27"))
+	assert.Equal(t, 1, CompareVersions("3.0", "2.5.6"))
+}
+
+func TestCheck(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	previousLookPath := LookPath
+	defer func() { LookPath = previousLookPath }()
+	LookPath = func(name string) (string, error) {
+		if name == "findmnt" {
+			return "", fmt.Errorf("not found")
+		}
+		return "/usr/sbin/" + name, nil
+	}
+
+	previous := commands.SetRunner(versionRunner{
+		"parted": {"parted (GNU parted) 3.5\n", ""},
+		"blkid":  {"blkid from util-linux 2.38.1  (libblkid 2.38.1, 20-Dec-2022)\n", ""},
+		"lsblk":  {"lsblk from util-linux 2.25.2\n", ""},
+		"rsync":  {"rsync  version 3.2.7  protocol version 31\n", ""},
+		"e2fsck": {"", "e2fsck 1.47.0 (5-Feb-2023)\n"},
+	})
+	defer commands.SetRunner(previous)
+
+	report := Check(context.Background(), ModeDiscover, ModeRsync)
+	assert.False(t, report.Ok())
+	assert.Len(t, report.Tools, 5)
+
+	failed := map[string]string{}
+	for _, f := range report.Failed() {
+		failed[f.Tool.Name] = f.Error
+	}
+	assert.Equal(t, map[string]string{"findmnt": "not installed", "lsblk": "version 2.27 required for JSON output"}, failed)
+
+	assert.Error(t, Require(context.Background(), ModeDiscover))
+	assert.NoError(t, Require(context.Background(), ModeRsync))
+
+	// restores require the tools of their mode only
+	report = Check(context.Background(), ModeRestoreRsync)
+	if assert.Len(t, report.Tools, 1) {
+		assert.Equal(t, "rsync", report.Tools[0].Tool.Name)
+	}
+	report = Check(context.Background(), ModeRestoreDD, ModeLuks)
+	assert.Len(t, report.Tools, 3)
+
+	// versions reported on stderr
+	tr := checkTool(context.Background(), Tool{Name: "e2fsck", VersionArgs: []string{"-V"}})
+	assert.Equal(t, "1.47.0", tr.Version)
+}
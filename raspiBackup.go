@@ -9,13 +9,18 @@ package main
 //#######################################################################################################################
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/framps/raspiBackupNext/discover"
+	"github.com/framps/raspiBackupNext/backup"
+	"github.com/framps/raspiBackupNext/commands"
 	"github.com/framps/raspiBackupNext/model"
+	"github.com/framps/raspiBackupNext/preflight"
 	"github.com/framps/raspiBackupNext/tools"
 )
 
@@ -25,40 +30,209 @@ func main() {
 	var collectFlag = flag.Bool("collect", false, "Collect system information")
 	var discoverFlag = flag.Bool("discover", false, "Discover system information")
 	var parallelFlag = flag.Bool("parallel", false, "Enable parallel execution")
+	var workersFlag = flag.Int("workers", commands.MaxWorkers, "Maximum number of tools and disks probed concurrently with -parallel")
+	var timingFlag = flag.Bool("timing", false, "Print the duration of each discovery step")
+	var strictFlag = flag.Bool("strict", false, "Fail if a disk or a tool could not be discovered completely")
+	var recordFlag = flag.String("record", "", "Record all executed commands into fixture directory")
+	var replayFlag = flag.String("replay", "", "Replay all commands from fixture directory instead of executing them")
+	var dryrunFlag = flag.Bool("dryrun", false, "Print commands which modify the system instead of executing them")
+	var preflightFlag = flag.Bool("preflight", false, "Check all required tools and their versions")
+	var backupFlag = flag.String("backup", "", "Create a backup with mode rsync, tar, dd or snapshot")
+	var restoreFlag = flag.String("restore", "", "Restore a backup with mode rsync, tar or dd")
+	var sourceFlag = flag.String("source", "/", "Backup source, a directory for rsync and tar or a device for dd. Restore source, the backup directory or file")
+	var targetFlag = flag.String("target", "", "Backup target, a directory for rsync or a file for tar and dd. Restore target, a directory for rsync and tar or a device for dd")
+	var luksHeaderFlag = flag.String("luksheader", "", "LUKS header backup restored with a dd image")
+	var linkDestFlag = flag.String("linkdest", "", "Previous rsync backup used for hardlinks")
+	var sendFlag = flag.Bool("send", false, "Snapshot backup with btrfs send into a btrfs target instead of rsync")
+	var parentFlag = flag.String("parent", "", "Parent snapshot of an incremental btrfs send, default is the previous snapshot")
+	var backendFlag = flag.String("backend", "commands", "Discovery backend of -discover and -collect, commands uses lsblk, blkid, parted and findmnt, sysfs reads sysfs and procfs without root")
+	var sysrootFlag = flag.String("sysroot", "/", "Root directory of sysfs and procfs used by the sysfs backend")
+	var inspectFlag = flag.String("inspect", "", "Print the partition table of an image file")
 	flag.Parse()
 
 	tools.NewLogger(*debugFlag)
+	commands.MaxWorkers = *workersFlag
+	commands.SetDryRun(*dryrunFlag, os.Stdout)
 
-	if !*collectFlag && !*discoverFlag {
+	if *backendFlag != "commands" && *backendFlag != "sysfs" {
+		fmt.Fprintf(os.Stderr, "Invalid backend %s\n", *backendFlag)
+		os.Exit(1)
+	}
+
+	// the sysfs backend discovers the system without root privileges
+	needsRoot := *replayFlag == "" && (*backendFlag == "commands" && *inspectFlag == "" || *backupFlag != "" || *restoreFlag != "")
+	if _, err := commands.ResolveEscalation(); err != nil && needsRoot {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if err := setupRunner(*recordFlag, *replayFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if !*collectFlag && !*discoverFlag && !*preflightFlag && *backupFlag == "" && *restoreFlag == "" && *inspectFlag == "" {
 		*discoverFlag = true
 	}
 
+	// SIGINT or SIGTERM cancel all running commands
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	exitCode := 0
 	start := time.Now()
-	if *collectFlag {
-		collectSystem(*parallelFlag)
+	if *preflightFlag && !preflightCheck(ctx) {
+		exitCode = 1
+	}
+	if *collectFlag && !collectSystem(ctx, *parallelFlag, *strictFlag, *timingFlag, *backendFlag, *sysrootFlag) {
+		exitCode = 1
+	}
+	if *discoverFlag && !discoverSystem(ctx, *parallelFlag, *strictFlag, *timingFlag, *backendFlag, *sysrootFlag) {
+		exitCode = 1
+	}
+	if *inspectFlag != "" && !inspectImage(*inspectFlag) {
+		exitCode = 1
+	}
+	if *backupFlag != "" {
+		options := backup.Options{Mode: backup.Mode(*backupFlag), Source: *sourceFlag, Target: *targetFlag, LinkDest: *linkDestFlag,
+			Send: *sendFlag, Parent: *parentFlag}
+		if err := backup.Run(ctx, options); err != nil {
+			fmt.Fprintf(os.Stderr, "Backup failed: %s\n", err.Error())
+			exitCode = 1
+		}
 	}
-	if *discoverFlag {
-		discoverSystem(*parallelFlag)
+	if *restoreFlag != "" {
+		options := backup.RestoreOptions{Mode: backup.Mode(*restoreFlag), Source: *sourceFlag, Target: *targetFlag, Header: *luksHeaderFlag}
+		if err := backup.Restore(ctx, options); err != nil {
+			fmt.Fprintf(os.Stderr, "Restore failed: %s\n", err.Error())
+			exitCode = 1
+		}
 	}
 	end := time.Now()
 	tools.Logger.Debug("Execution time ", end.Sub(start))
-	os.Exit(0)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Interrupted\n")
+		os.Exit(130)
+	}
+	os.Exit(exitCode)
+}
+
+func setupRunner(recordDirectory, replayDirectory string) error {
+
+	switch {
+	case recordDirectory != "" && replayDirectory != "":
+		return fmt.Errorf("Options -record and -replay are mutually exclusive")
+	case recordDirectory != "":
+		recorder, err := commands.NewRecordingRunner(recordDirectory, commands.ExecRunner{})
+		if err != nil {
+			return err
+		}
+		commands.SetRunner(recorder)
+	case replayDirectory != "":
+		replayer, err := commands.NewReplayRunner(replayDirectory)
+		if err != nil {
+			return err
+		}
+		commands.SetRunner(replayer)
+	}
+	return nil
 }
 
-func collectSystem(parallelExecution bool) {
-	fmt.Printf("=== Collect system ===\n\n%s\n", discover.NewSystem(parallelExecution))
+func preflightCheck(ctx context.Context) bool {
+	report := preflight.Check(ctx, preflight.Modes...)
+	fmt.Printf("=== Preflight ===\n\n%s\n", report)
+	return report.Ok()
 }
 
-func discoverSystem(parallelExecution bool) {
+func inspectImage(fileName string) bool {
+	disk, err := model.NewDiskFromImage(fileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", fileName, err.Error())
+		return false
+	}
+	fmt.Printf("=== Inspect %s ===\n\n%s\n", fileName, disk)
+	return true
+}
+
+// discoveryFailed - reports the failures of a discovery. A discovery without system is always a failure, partially
+// discovered systems are a failure in strict mode only
+func discoveryFailed(err error, discovered, strict bool) bool {
+	if err == nil {
+		return false
+	}
+	if !discovered {
+		fmt.Fprintf(os.Stderr, "Discovery failed: %s\n", err.Error())
+		return true
+	}
+	failures := commands.AsDiscoveryErrors(err)
+	if failures == nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err.Error())
+	}
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", f.Error())
+	}
+	return strict
+}
+
+// printTimings - prints the durations of the discovery steps if available
+func printTimings(timings *commands.Timings) {
+	if timings != nil {
+		fmt.Printf("=== Timing ===\n\n%s\n", timings)
+	}
+}
+
+// printWarnings - prints the inconsistencies between the providers of the discovered system
+func printWarnings(system *model.System) {
+	for _, warning := range system.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
+
+// providers - providers of the discovery backend
+func providers(backend, sysroot string) []model.Provider {
+	if backend == "sysfs" {
+		return model.SysfsProviders(sysroot)
+	}
+	return model.CommandProviders()
+}
+
+func collectSystem(ctx context.Context, parallelExecution, strict, timing bool, backend, sysroot string) bool {
+	discovery, err := model.Discover(ctx, providers(backend, sysroot), parallelExecution)
+	if discoveryFailed(err, discovery.System != nil, strict) || discovery.System == nil {
+		return false
+	}
+	printWarnings(discovery.System)
+	fmt.Printf("=== Collect system ===\n\n%s\n", discovery)
+	if timing {
+		printTimings(discovery.Timings)
+	}
+	return true
+}
+
+func discoverSystem(ctx context.Context, parallelExecution, strict, timing bool, backend, sysroot string) bool {
 	fmt.Printf("=== Discover system ===\n\n")
-	system, err := model.NewSystem(parallelExecution)
-	tools.HandleError(err)
+	discovery, err := model.Discover(ctx, providers(backend, sysroot), parallelExecution)
+	system := discovery.System
+	if discoveryFailed(err, system != nil, strict) || system == nil {
+		return false
+	}
+	printWarnings(system)
 	fmt.Printf("*** From system:\n%s\n", system)
+	if timing {
+		printTimings(system.Timings)
+	}
+	if commands.DryRun() {
+		fmt.Printf("DRY-RUN: write system.model\n")
+		return true
+	}
 	if err = system.ToJSON("system.model"); err != nil {
-		tools.HandleError(err)
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
 	}
-	if system, err = model.NewSystemFromJSON("system.model"); system != nil {
-		tools.HandleError(err)
+	if system, err = model.NewSystemFromJSON("system.model"); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
 	}
 	fmt.Printf("*** From json:\n%s\n", system)
+	return true
 }
@@ -0,0 +1,90 @@
+package sysfs
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DeviceMapper - device mapper device, e.g. an open LUKS container or a LVM logical volume
+type DeviceMapper struct {
+	Name   string   // dm-0
+	Mapper string   // cryptroot, the device is /dev/mapper/cryptroot
+	Dev    string   // major:minor, e.g. 254:0
+	Uuid   string   // CRYPT-LUKS2-e4b0c6a23f1d4a8b9c2e7d5f1a3b6c8e-cryptroot or LVM-...
+	Size   int64    // bytes
+	Slaves []string // devices the mapping is stacked on, e.g. mmcblk0p2
+}
+
+func (d DeviceMapper) String() string {
+	return fmt.Sprintf("DeviceMapper: %s Mapper: %s Dev: %s Uuid: %s Size: %d Slaves: %s", d.Name, d.Mapper, d.Dev, d.Uuid, d.Size,
+		strings.Join(d.Slaves, ","))
+}
+
+// Subsystem - creator of the mapping taken from the uuid prefix, e.g. CRYPT or LVM
+func (d DeviceMapper) Subsystem() string {
+	if i := strings.Index(d.Uuid, "-"); i > 0 {
+		return d.Uuid[:i]
+	}
+	return ""
+}
+
+// CryptType - LUKS1, LUKS2 or PLAIN for dm-crypt mappings, empty otherwise
+func (d DeviceMapper) CryptType() string {
+	if d.Subsystem() != "CRYPT" {
+		return ""
+	}
+	return strings.SplitN(d.Uuid, "-", 3)[1]
+}
+
+// DeviceMappers - all device mapper devices. A missing device mapper is no error
+func (r *Reader) DeviceMappers() ([]*DeviceMapper, error) {
+
+	entries, err := ioutil.ReadDir(r.path("sys", "block"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*DeviceMapper
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "dm-") {
+			continue
+		}
+
+		d := &DeviceMapper{Name: name}
+		if d.Mapper, err = r.readString("sys", "block", name, "dm", "name"); err != nil {
+			return nil, err
+		}
+		if d.Dev, err = r.readString("sys", "block", name, "dev"); err != nil {
+			return nil, err
+		}
+		d.Uuid, _ = r.readString("sys", "block", name, "dm", "uuid")
+		sectors, _ := r.readInt("sys", "block", name, "size")
+		d.Size = sectors * SectorSize
+
+		slaves, err := ioutil.ReadDir(r.path("sys", "block", name, "slaves"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, s := range slaves {
+			d.Slaves = append(d.Slaves, s.Name())
+		}
+		result = append(result, d)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
@@ -0,0 +1,173 @@
+package sysfs
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MdMember - member device of a md array listed in /proc/mdstat
+type MdMember struct {
+	Name   string // sda1
+	Role   int    // slot number in the array
+	Faulty bool   // (F)
+	Spare  bool   // (S)
+}
+
+func (m MdMember) String() string {
+	return fmt.Sprintf("Member: %s Role: %d Faulty: %t Spare: %t", m.Name, m.Role, m.Faulty, m.Spare)
+}
+
+// MdArray - md software raid array listed in /proc/mdstat
+type MdArray struct {
+	Name        string // md0
+	State       string // active, inactive
+	ReadOnly    bool   // (read-only) or (auto-read-only)
+	Level       string // raid1, empty for inactive arrays
+	Members     []*MdMember
+	Blocks      int64  // 1K blocks
+	RaidDisks   int    // [2/1]
+	ActiveDisks int    // [2/1]
+	Status      string // [U_]
+	Sync        string // recovery = 8.5%, resync = DELAYED
+}
+
+func (a MdArray) String() string {
+	names := make([]string, 0, len(a.Members))
+	for _, m := range a.Members {
+		names = append(names, m.Name)
+	}
+	return fmt.Sprintf("MdArray: %s State: %s Level: %s Members: %s Blocks: %d Disks: %d/%d Status: %s Sync: %s",
+		a.Name, a.State, a.Level, strings.Join(names, ","), a.Blocks, a.RaidDisks, a.ActiveDisks, a.Status, a.Sync)
+}
+
+// Degraded - less active disks than raid disks or a faulty member
+func (a MdArray) Degraded() bool {
+	if a.ActiveDisks < a.RaidDisks {
+		return true
+	}
+	for _, m := range a.Members {
+		if m.Faulty {
+			return true
+		}
+	}
+	return false
+}
+
+// Member - member device with name, e.g. sda1, or nil
+func (a MdArray) Member(name string) *MdMember {
+	for _, m := range a.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+var (
+	mdArrayLine  = regexp.MustCompile(`^(md\S+) : (\S+)(?: \(([a-z-]+)\))?(.*)$`)
+	mdMember     = regexp.MustCompile(`^(\S+)\[(\d+)\]((?:\([A-Z]\))*)$`)
+	mdBlocks     = regexp.MustCompile(`^\s+(\d+) blocks`)
+	mdDisks      = regexp.MustCompile(`\[(\d+)/(\d+)\] \[([U_]+)\]`)
+	mdSyncStatus = regexp.MustCompile(`(recovery|resync|reshape|check|repair)\s*=\s*(\S+)`)
+)
+
+/*
+Personalities : [raid1]
+md0 : active raid1 sdb1[1] sda1[0]
+      976630464 blocks super 1.2 [2/2] [UU]
+      bitmap: 0/8 pages [0KB], 65536KB chunk
+
+md1 : active raid1 sdd1[2](F) sdc1[0]
+      488254464 blocks super 1.2 [2/1] [U_]
+
+unused devices: <none>
+*/
+
+// ParseMdstat - parses /proc/mdstat, see md(4). Arrays are sorted by name
+func ParseMdstat(reader io.Reader) ([]*MdArray, error) {
+
+	var (
+		result []*MdArray
+		array  *MdArray
+	)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if matches := mdArrayLine.FindStringSubmatch(line); matches != nil {
+			array = &MdArray{Name: matches[1], State: matches[2], ReadOnly: strings.HasSuffix(matches[3], "read-only")}
+			for _, field := range strings.Fields(matches[4]) {
+				member := mdMember.FindStringSubmatch(field)
+				if member == nil {
+					if array.Level == "" && len(array.Members) == 0 {
+						array.Level = field
+						continue
+					}
+					return nil, fmt.Errorf("Invalid mdstat member '%s' of %s", field, array.Name)
+				}
+				m := &MdMember{Name: member[1], Faulty: strings.Contains(member[3], "(F)"), Spare: strings.Contains(member[3], "(S)")}
+				m.Role, _ = strconv.Atoi(member[2])
+				array.Members = append(array.Members, m)
+			}
+			sort.Slice(array.Members, func(i, j int) bool {
+				return array.Members[i].Role < array.Members[j].Role
+			})
+			result = append(result, array)
+			continue
+		}
+
+		if array == nil || !strings.HasPrefix(line, " ") {
+			array = nil
+			continue
+		}
+		if matches := mdBlocks.FindStringSubmatch(line); matches != nil {
+			array.Blocks, _ = strconv.ParseInt(matches[1], 10, 64)
+		}
+		if matches := mdDisks.FindStringSubmatch(line); matches != nil {
+			array.RaidDisks, _ = strconv.Atoi(matches[1])
+			array.ActiveDisks, _ = strconv.Atoi(matches[2])
+			array.Status = "[" + matches[3] + "]"
+		}
+		if matches := mdSyncStatus.FindStringSubmatch(line); matches != nil {
+			array.Sync = matches[1] + " = " + matches[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// Mdstat - md arrays of /proc/mdstat. No arrays are returned if the md driver isn't loaded
+func (r *Reader) Mdstat() ([]*MdArray, error) {
+
+	f, err := os.Open(r.path("proc", "mdstat"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseMdstat(f)
+}
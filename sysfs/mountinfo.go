@@ -0,0 +1,109 @@
+package sysfs
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Mount - entry of /proc/self/mountinfo
+type Mount struct {
+	ID           int
+	Parent       int
+	Dev          string // major:minor, e.g. 179:2. Also valid for /dev/root
+	Root         string // directory of the filesystem mounted, e.g. the source of a bind mount or a btrfs subvolume
+	Mountpoint   string
+	Options      string   // per mount options
+	Optional     []string // shared:1, master:2
+	FSType       string
+	Source       string // /dev/root, /dev/mmcblk0p1, server:/export
+	SuperOptions string // per filesystem options
+	Bind         bool   // detected by MountTable
+}
+
+func (m Mount) String() string {
+	return fmt.Sprintf("Mount: %s Dev: %s Root: %s Source: %s FSType: %s Options: %s Bind: %t", m.Mountpoint, m.Dev, m.Root, m.Source, m.FSType, m.Options, m.Bind)
+}
+
+/*
+21 1 179:2 / / rw,noatime shared:1 - ext4 /dev/root rw
+30 21 179:1 / /boot/firmware rw,relatime shared:14 - vfat /dev/mmcblk0p1 rw,fmask=0022,dmask=0022
+*/
+
+// ParseMountinfo - parses mountinfo, see proc(5)
+func ParseMountinfo(reader io.Reader) ([]*Mount, error) {
+
+	var result []*Mount
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		separator := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 0 || len(fields) < separator+3 {
+			return nil, fmt.Errorf("Invalid mountinfo line '%s'", line)
+		}
+
+		m := &Mount{Dev: fields[2], Root: unescapeMountinfo(fields[3]), Mountpoint: unescapeMountinfo(fields[4]), Options: fields[5],
+			Optional: fields[6:separator], FSType: fields[separator+1], Source: unescapeMountinfo(fields[separator+2])}
+		m.ID, _ = strconv.Atoi(fields[0])
+		m.Parent, _ = strconv.Atoi(fields[1])
+		if len(fields) > separator+3 {
+			m.SuperOptions = fields[separator+3]
+		}
+		result = append(result, m)
+	}
+	return result, scanner.Err()
+}
+
+// unescapeMountinfo - the kernel escapes blank, tab, newline and backslash as octal, e.g. \040
+func unescapeMountinfo(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+4 <= len(value) {
+			if n, err := strconv.ParseUint(value[i+1:i+4], 8, 8); err == nil {
+				result.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		result.WriteByte(value[i])
+	}
+	return result.String()
+}
+
+// Mounts - mounts of the current process
+func (r *Reader) Mounts() ([]*Mount, error) {
+
+	f, err := os.Open(r.path("proc", "self", "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseMountinfo(f)
+}
@@ -0,0 +1,223 @@
+package sysfs
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bytes"
+	"strings"
+)
+
+// BootMountpoints - mountpoints of the boot partition, /boot/firmware since Debian Bookworm
+var BootMountpoints = []string{"/boot/firmware", "/boot"}
+
+// networkFilesystems - filesystems located on other systems
+var networkFilesystems = map[string]bool{"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true, "9p": true,
+	"ceph": true, "glusterfs": true, "davfs": true, "fuse.sshfs": true, "fuse.glusterfs": true, "fuse.rclone": true}
+
+// maxOverlayDepth - overlay mounts stacked on overlay mounts are resolved up to this depth
+const maxOverlayDepth = 8
+
+// IsNetwork - filesystem is located on another system, e.g. nfs or cifs
+func (m Mount) IsNetwork() bool {
+	return networkFilesystems[m.FSType]
+}
+
+// IsOverlay - overlayfs, e.g. used by the overlay file system option of raspi-config
+func (m Mount) IsOverlay() bool {
+	return m.FSType == "overlay"
+}
+
+// Lowerdir - lowest layer of an overlay mount, e.g. /media/root-ro
+func (m Mount) Lowerdir() string {
+	for _, option := range strings.Split(m.SuperOptions, ",") {
+		if strings.HasPrefix(option, "lowerdir=") {
+			dirs := strings.Split(strings.TrimPrefix(option, "lowerdir="), ":")
+			return dirs[len(dirs)-1]
+		}
+	}
+	return ""
+}
+
+// isBelow - path is dir or located below dir
+func isBelow(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// MountTable - all mounts of the system
+type MountTable struct {
+	Mounts []*Mount // in mount order
+}
+
+// NewMountTable - creates the mount table and detects bind mounts. A mount is a bind mount if the same filesystem was
+// mounted before with the same or a parent root or if a directory of a filesystem other than btrfs is mounted.
+// btrfs mounts subvolumes with the subvolume as root
+func NewMountTable(mounts []*Mount) *MountTable {
+	for i, m := range mounts {
+		for _, previous := range mounts[:i] {
+			if previous.Dev == m.Dev && isBelow(m.Root, previous.Root) {
+				m.Bind = true
+				break
+			}
+		}
+		if m.Root != "/" && m.FSType != "btrfs" && !m.IsNetwork() {
+			m.Bind = true
+		}
+	}
+	return &MountTable{Mounts: mounts}
+}
+
+func (t MountTable) String() string {
+	var result bytes.Buffer
+	for _, m := range t.Mounts {
+		result.WriteString(m.String())
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+// Find - mount visible at mountpoint, i.e. the last one mounted, or nil
+func (t *MountTable) Find(mountpoint string) *Mount {
+	for i := len(t.Mounts) - 1; i >= 0; i-- {
+		if t.Mounts[i].Mountpoint == mountpoint {
+			return t.Mounts[i]
+		}
+	}
+	return nil
+}
+
+// Containing - mount of the filesystem containing path, e.g. / for /home/pi if /home is no mountpoint
+func (t *MountTable) Containing(path string) *Mount {
+	var result *Mount
+	for _, m := range t.Mounts {
+		if isBelow(path, m.Mountpoint) && (result == nil || len(m.Mountpoint) >= len(result.Mountpoint)) {
+			result = m
+		}
+	}
+	return result
+}
+
+// FindDev - first mount of the device with major:minor dev which is no bind mount, or nil
+func (t *MountTable) FindDev(dev string) *Mount {
+	for _, m := range t.Mounts {
+		if m.Dev == dev && !m.Bind {
+			return m
+		}
+	}
+	return nil
+}
+
+// Bootmount - mount of the boot partition. The first of BootMountpoints with a vfat filesystem which is no bind mount
+// is used, or nil
+func (t *MountTable) Bootmount() *Mount {
+	for _, mountpoint := range BootMountpoints {
+		if m := t.Find(mountpoint); m != nil && m.FSType == "vfat" && !m.Bind {
+			return m
+		}
+	}
+	return nil
+}
+
+// Devices - block devices by major:minor, PARTUUID, UUID and LABEL used to resolve mount sources
+type Devices struct {
+	ByDev      map[string]string
+	ByPartuuid map[string]string
+	ByUuid     map[string]string
+	ByLabel    map[string]string
+	Parents    map[string]string // device stacked on exactly one other device, e.g. /dev/mapper/cryptroot -> /dev/mmcblk0p2
+}
+
+// NewDevices -
+func NewDevices() *Devices {
+	return &Devices{ByDev: make(map[string]string), ByPartuuid: make(map[string]string), ByUuid: make(map[string]string),
+		ByLabel: make(map[string]string), Parents: make(map[string]string)}
+}
+
+// AddParent - adds the device a device is stacked on. Devices stacked on more than one device, e.g. logical volumes
+// spanning multiple physical volumes, have no parent
+func (d *Devices) AddParent(device, parent string) {
+	if previous, ok := d.Parents[device]; ok && previous != parent {
+		d.Parents[device] = ""
+		return
+	}
+	d.Parents[device] = parent
+}
+
+// Partition - partition or disk a device is stacked on, e.g. /dev/mmcblk0p2 for /dev/mapper/cryptroot
+func (d *Devices) Partition(device string) string {
+	for i := 0; i < maxOverlayDepth; i++ {
+		parent, ok := d.Parents[device]
+		if !ok || parent == "" {
+			break
+		}
+		device = parent
+	}
+	return device
+}
+
+// Add - adds a device, e.g. /dev/mmcblk0p2. Empty attributes are ignored
+func (d *Devices) Add(device, dev, partuuid, uuid, label string) {
+	for _, a := range []struct {
+		byAttribute map[string]string
+		value       string
+	}{{d.ByDev, dev}, {d.ByPartuuid, partuuid}, {d.ByUuid, uuid}, {d.ByLabel, label}} {
+		if a.value != "" && a.value != "N/A" {
+			a.byAttribute[a.value] = device
+		}
+	}
+}
+
+// Device - block device of a mount or "". Sources like /dev/root, PARTUUID=, UUID= and LABEL= are resolved and overlay
+// mounts are resolved to the device of their lowest layer. Network and pseudo filesystems have no device
+func (t *MountTable) Device(m *Mount, devices *Devices) string {
+	return t.device(m, devices, 0)
+}
+
+func (t *MountTable) device(m *Mount, devices *Devices, depth int) string {
+
+	switch {
+	case m == nil || m.IsNetwork() || depth > maxOverlayDepth:
+		return ""
+	case m.IsOverlay():
+		if lowerdir := m.Lowerdir(); lowerdir != "" {
+			return t.device(t.Containing(lowerdir), devices, depth+1)
+		}
+		return ""
+	}
+
+	for _, reference := range []struct {
+		prefix      string
+		byAttribute map[string]string
+	}{
+		{"PARTUUID=", devices.ByPartuuid}, {"/dev/disk/by-partuuid/", devices.ByPartuuid},
+		{"UUID=", devices.ByUuid}, {"/dev/disk/by-uuid/", devices.ByUuid},
+		{"LABEL=", devices.ByLabel}, {"/dev/disk/by-label/", devices.ByLabel},
+	} {
+		if strings.HasPrefix(m.Source, reference.prefix) {
+			return reference.byAttribute[strings.Trim(strings.TrimPrefix(m.Source, reference.prefix), `"`)]
+		}
+	}
+
+	// /dev/root and device mapper symlinks are resolved by major:minor
+	if device, ok := devices.ByDev[m.Dev]; ok {
+		return device
+	}
+	if strings.HasPrefix(m.Source, "/dev/") && m.Source != "/dev/root" {
+		return m.Source
+	}
+	return ""
+}
+
+// MountTable - mount table of the current process
+func (r *Reader) MountTable() (*MountTable, error) {
+	mounts, err := r.Mounts()
+	if err != nil {
+		return nil, err
+	}
+	return NewMountTable(mounts), nil
+}
@@ -0,0 +1,293 @@
+package sysfs
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/framps/raspiBackupNext/tools"
+)
+
+// SectorSize - sysfs reports start and size in 512 byte sectors independent of the logical block size of the device
+const SectorSize = 512
+
+// Reader - reads block devices and mounts from sysfs and procfs below a root directory. The root is / for the
+// running system and a fake tree for tests. No root privileges and no external tools are required
+type Reader struct {
+	Root string
+}
+
+// NewReader -
+func NewReader(root string) *Reader {
+	if root == "" {
+		root = "/"
+	}
+	return &Reader{Root: root}
+}
+
+func (r *Reader) path(elem ...string) string {
+	return filepath.Join(append([]string{r.Root}, elem...)...)
+}
+
+// readString - value of a sysfs attribute
+func (r *Reader) readString(elem ...string) (string, error) {
+	b, err := ioutil.ReadFile(r.path(elem...))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readInt - numeric value of a sysfs attribute
+func (r *Reader) readInt(elem ...string) (int64, error) {
+	s, err := r.readString(elem...)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Dev - major:minor of a block device, e.g. 9:0 for md0
+func (r *Reader) Dev(name string) (string, error) {
+	return r.readString("sys", "block", name, "dev")
+}
+
+// Partition -
+type Partition struct {
+	Name     string // sda1, mmcblk0p1, nvme0n1p1
+	Number   int
+	Dev      string // major:minor, e.g. 8:1
+	Start    int64  // bytes
+	Size     int64  // bytes
+	ReadOnly bool
+}
+
+func (p Partition) String() string {
+	return fmt.Sprintf("Partition: %s Number: %d Dev: %s Start: %d Size: %d RO: %t", p.Name, p.Number, p.Dev, p.Start, p.Size, p.ReadOnly)
+}
+
+// Disk -
+type Disk struct {
+	Name              string // sda, mmcblk0, nvme0n1
+	Dev               string // major:minor, e.g. 8:0
+	Size              int64  // bytes
+	LogicalBlockSize  int
+	PhysicalBlockSize int
+	Removable         bool
+	ReadOnly          bool
+	Model             string
+	Partitions        map[int]*Partition
+}
+
+func (d Disk) String() string {
+	var result bytes.Buffer
+	result.WriteString(fmt.Sprintf("Disk: %s Dev: %s Size: %d Sector size: %d/%d Removable: %t RO: %t Model: %s\n",
+		d.Name, d.Dev, d.Size, d.LogicalBlockSize, d.PhysicalBlockSize, d.Removable, d.ReadOnly, d.Model))
+
+	index := make([]*Partition, 0, len(d.Partitions))
+	for _, partition := range d.Partitions {
+		index = append(index, partition)
+	}
+
+	sort.Slice(index, func(i, j int) bool {
+		return index[i].Number < index[j].Number
+	})
+
+	for _, partition := range index {
+		result.WriteString(fmt.Sprintf("%s\n", partition))
+	}
+	return result.String()
+}
+
+// Disks -
+type Disks struct {
+	Disks map[string]*Disk
+}
+
+func (d Disks) String() string {
+	var result bytes.Buffer
+
+	index := make([]*Disk, 0, len(d.Disks))
+	for _, disk := range d.Disks {
+		index = append(index, disk)
+	}
+
+	sort.Slice(index, func(i, j int) bool {
+		return index[i].Name < index[j].Name
+	})
+
+	for _, disk := range index {
+		result.WriteString(disk.String())
+	}
+	return result.String()
+}
+
+// ProcPartition - entry of /proc/partitions
+type ProcPartition struct {
+	Major  int
+	Minor  int
+	Blocks int64 // 1K blocks
+	Name   string
+}
+
+/*
+major minor  #blocks  name
+
+ 179        0   15558144 mmcblk0
+ 179        1     520192 mmcblk0p1
+*/
+
+// ProcPartitions - all block devices known to the kernel. Removable devices without medium are not listed
+func (r *Reader) ProcPartitions() ([]ProcPartition, error) {
+
+	b, err := ioutil.ReadFile(r.path("proc", "partitions"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ProcPartition
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			continue
+		}
+		major, err := strconv.Atoi(fields[0])
+		if err != nil { // header
+			continue
+		}
+		minor, _ := strconv.Atoi(fields[1])
+		blocks, _ := strconv.ParseInt(fields[2], 10, 64)
+		result = append(result, ProcPartition{Major: major, Minor: minor, Blocks: blocks, Name: fields[3]})
+	}
+	return result, nil
+}
+
+// NewDisks - all disks with their partitions. Virtual devices like loop, ram, zram, dm and md, optical drives and
+// removable devices without medium are skipped
+func (r *Reader) NewDisks() (*Disks, error) {
+
+	procPartitions, err := r.ProcPartitions()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(procPartitions))
+	for _, p := range procPartitions {
+		known[p.Name] = true
+	}
+
+	entries, err := ioutil.ReadDir(r.path("sys", "block"))
+	if err != nil {
+		return nil, err
+	}
+
+	disks := Disks{make(map[string]*Disk, 16)}
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case !known[name]:
+			tools.Logger.Debugf("Skipping %s: no medium", name)
+			continue
+		case strings.HasPrefix(name, "sr"):
+			tools.Logger.Debugf("Skipping %s: optical drive", name)
+			continue
+		}
+		if _, err := os.Stat(r.path("sys", "block", name, "device")); err != nil {
+			tools.Logger.Debugf("Skipping %s: virtual device", name)
+			continue
+		}
+
+		disk, err := r.newDisk(name)
+		if err != nil {
+			return nil, err
+		}
+		disks.Disks[name] = disk
+	}
+	return &disks, nil
+}
+
+func (r *Reader) newDisk(name string) (*Disk, error) {
+
+	disk := &Disk{Name: name, Partitions: make(map[int]*Partition, 16)}
+
+	var err error
+	if disk.Dev, err = r.readString("sys", "block", name, "dev"); err != nil {
+		return nil, err
+	}
+	sectors, err := r.readInt("sys", "block", name, "size")
+	if err != nil {
+		return nil, err
+	}
+	disk.Size = sectors * SectorSize
+
+	// optional attributes
+	n, _ := r.readInt("sys", "block", name, "queue", "logical_block_size")
+	disk.LogicalBlockSize = int(n)
+	n, _ = r.readInt("sys", "block", name, "queue", "physical_block_size")
+	disk.PhysicalBlockSize = int(n)
+	n, _ = r.readInt("sys", "block", name, "removable")
+	disk.Removable = n == 1
+	n, _ = r.readInt("sys", "block", name, "ro")
+	disk.ReadOnly = n == 1
+	if disk.Model, err = r.readString("sys", "block", name, "device", "model"); err != nil {
+		disk.Model, _ = r.readString("sys", "block", name, "device", "name") // mmc
+	}
+
+	entries, err := ioutil.ReadDir(r.path("sys", "block", name))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		number, err := r.readInt("sys", "block", name, e.Name(), "partition")
+		if err != nil { // holders, queue, ...
+			continue
+		}
+		partition, err := r.newPartition(name, e.Name(), int(number))
+		if err != nil {
+			return nil, err
+		}
+		disk.Partitions[partition.Number] = partition
+	}
+
+	return disk, nil
+}
+
+func (r *Reader) newPartition(disk, name string, number int) (*Partition, error) {
+
+	partition := &Partition{Name: name, Number: number}
+
+	var err error
+	if partition.Dev, err = r.readString("sys", "block", disk, name, "dev"); err != nil {
+		return nil, err
+	}
+	start, err := r.readInt("sys", "block", disk, name, "start")
+	if err != nil {
+		return nil, err
+	}
+	size, err := r.readInt("sys", "block", disk, name, "size")
+	if err != nil {
+		return nil, err
+	}
+	partition.Start, partition.Size = start*SectorSize, size*SectorSize
+	n, _ := r.readInt("sys", "block", disk, name, "ro")
+	partition.ReadOnly = n == 1
+
+	return partition, nil
+}
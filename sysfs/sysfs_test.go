@@ -0,0 +1,175 @@
+package sysfs
+
+//######################################################################################################################
+//
+//    Next raspiBackup version written in go
+//
+//    Copyright (C) 2018 framp at linux-tips-and-tricks dot de
+//
+//#######################################################################################################################
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/framps/raspiBackupNext/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisks(t *testing.T) {
+
+	tools.NewLogger(false)
+
+	disks, err := NewReader("testData/raspifix").NewDisks()
+	assert.NoError(t, err)
+
+	// sdc has no medium, sr0 is an optical drive and loop0 is virtual
+	assert.Len(t, disks.Disks, 2)
+
+	mmc := disks.Disks["mmcblk0"]
+	assert.Equal(t, "179:0", mmc.Dev)
+	assert.Equal(t, int64(15931539456), mmc.Size)
+	assert.Equal(t, "SL16G", mmc.Model)
+	assert.Len(t, mmc.Partitions, 2)
+	assert.Equal(t, "mmcblk0p2", mmc.Partitions[2].Name)
+	assert.Equal(t, int64(536870912), mmc.Partitions[2].Start)
+	assert.Equal(t, int64(15394668544), mmc.Partitions[2].Size)
+
+	sda := disks.Disks["sda"]
+	assert.Equal(t, "Elements 1078", sda.Model)
+	assert.Equal(t, 512, sda.LogicalBlockSize)
+	assert.Equal(t, 4096, sda.PhysicalBlockSize)
+	assert.Equal(t, int64(1048576), sda.Partitions[1].Start)
+}
+
+func TestMounts(t *testing.T) {
+
+	mounts, err := NewReader("testData/raspifix").Mounts()
+	assert.NoError(t, err)
+	assert.Len(t, mounts, 8)
+
+	root := mounts[0]
+	assert.Equal(t, "/", root.Mountpoint)
+	assert.Equal(t, "179:2", root.Dev)
+	assert.Equal(t, "/dev/root", root.Source)
+	assert.Equal(t, "ext4", root.FSType)
+	assert.Equal(t, []string{"shared:1"}, root.Optional)
+
+	assert.Equal(t, "/disks/my silver", mounts[6].Mountpoint)
+
+	_, err = ParseMountinfo(strings.NewReader("21 1 179:2 / / rw,noatime shared:1 ext4 /dev/root rw\n"))
+	assert.Error(t, err)
+}
+
+func TestMountTable(t *testing.T) {
+
+	table, err := NewReader("testData/raspifix").MountTable()
+	assert.NoError(t, err)
+
+	devices := NewDevices()
+	devices.Add("/dev/mmcblk0p1", "179:1", "2c3ce5a2-01", "3312-932F", "bootfs")
+	devices.Add("/dev/mmcblk0p2", "179:2", "2c3ce5a2-02", "64a5e86f-5ed3-4c9f-aab3-c4ae24bff95a", "rootfs")
+
+	assert.Equal(t, "/dev/mmcblk0p2", table.Device(table.Find("/"), devices))
+	assert.Equal(t, "/boot/firmware", table.Bootmount().Mountpoint)
+	assert.Equal(t, "/dev/mmcblk0p1", table.Device(table.Bootmount(), devices))
+	assert.Equal(t, "/", table.Containing("/home/pi").Mountpoint)
+	assert.Empty(t, table.Device(table.Find("/proc"), devices))
+
+	mounts, err := ParseMountinfo(strings.NewReader(`20 1 0:18 / / rw,relatime - overlay overlayroot rw,lowerdir=/media/root-ro,upperdir=/media/root-rw/overlay
+21 20 179:2 / /media/root-ro ro,relatime - ext4 PARTUUID=2c3ce5a2-02 ro
+22 20 179:1 / /boot rw,relatime - vfat UUID=3312-932F rw
+23 20 179:2 /home /mnt/home rw,relatime - ext4 /dev/root rw
+24 20 179:1 / /boot/firmware rw,relatime - vfat LABEL=bootfs rw
+25 20 0:52 / /mnt/nas rw,relatime - nfs4 nas:/export rw
+`))
+	assert.NoError(t, err)
+	table = NewMountTable(mounts)
+
+	assert.Equal(t, "/dev/mmcblk0p2", table.Device(table.Find("/"), devices))
+	assert.Equal(t, "/dev/mmcblk0p2", table.Device(table.Find("/mnt/home"), devices))
+	assert.True(t, table.Find("/mnt/home").Bind)
+	assert.True(t, table.Find("/boot/firmware").Bind)
+	assert.Equal(t, "/boot", table.Bootmount().Mountpoint)
+	assert.Equal(t, "/dev/mmcblk0p1", table.Device(table.Bootmount(), devices))
+	assert.True(t, table.Find("/mnt/nas").IsNetwork())
+	assert.Empty(t, table.Device(table.Find("/mnt/nas"), devices))
+	assert.Nil(t, table.Find("/data"))
+}
+
+func TestDeviceMappers(t *testing.T) {
+
+	mappers, err := NewReader("testData/luks").DeviceMappers()
+	assert.NoError(t, err)
+	if assert.Len(t, mappers, 1) {
+		assert.Equal(t, "cryptroot", mappers[0].Mapper)
+		assert.Equal(t, "254:0", mappers[0].Dev)
+		assert.Equal(t, "CRYPT", mappers[0].Subsystem())
+		assert.Equal(t, "LUKS2", mappers[0].CryptType())
+		assert.Equal(t, []string{"mmcblk0p2"}, mappers[0].Slaves)
+	}
+
+	mappers, err = NewReader("testData/raspifix").DeviceMappers()
+	assert.NoError(t, err)
+	assert.Empty(t, mappers)
+
+	devices := NewDevices()
+	devices.AddParent("/dev/mapper/cryptroot", "/dev/mmcblk0p2")
+	devices.AddParent("/dev/mapper/data-home", "/dev/sda1")
+	devices.AddParent("/dev/mapper/data-home", "/dev/sdb1")
+	assert.Equal(t, "/dev/mmcblk0p2", devices.Partition("/dev/mapper/cryptroot"))
+	assert.Equal(t, "/dev/mapper/data-home", devices.Partition("/dev/mapper/data-home"))
+	assert.Equal(t, "/dev/sda1", devices.Partition("/dev/sda1"))
+}
+
+func TestParseMdstat(t *testing.T) {
+
+	arrays, err := ParseMdstat(strings.NewReader(`Personalities : [raid1] [raid6] [raid5] [raid4]
+md1 : active raid5 sdf1[3](S) sde1[2] sdd1[1](F) sdc1[0]
+      1953259520 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/2] [U_U]
+      [=>...................]  recovery =  8.5% (83004416/976629760) finish=80.2min speed=185606K/sec
+
+md0 : active (auto-read-only) raid1 sdb1[1] sda1[0]
+      976630464 blocks super 1.2 [2/2] [UU]
+      bitmap: 0/8 pages [0KB], 65536KB chunk
+
+md127 : inactive sdg1[0](S)
+      976630464 blocks super 1.2
+
+unused devices: <none>
+`))
+	assert.NoError(t, err)
+	if !assert.Len(t, arrays, 3) {
+		return
+	}
+
+	md0 := arrays[0]
+	assert.Equal(t, "md0", md0.Name)
+	assert.Equal(t, "raid1", md0.Level)
+	assert.True(t, md0.ReadOnly)
+	assert.Equal(t, int64(976630464), md0.Blocks)
+	assert.Equal(t, "sda1", md0.Members[0].Name)
+	assert.False(t, md0.Degraded())
+
+	md1 := arrays[1]
+	assert.Equal(t, "raid5", md1.Level)
+	assert.Equal(t, 3, md1.RaidDisks)
+	assert.Equal(t, 2, md1.ActiveDisks)
+	assert.Equal(t, "[U_U]", md1.Status)
+	assert.Equal(t, "recovery = 8.5%", md1.Sync)
+	assert.True(t, md1.Member("sdd1").Faulty)
+	assert.True(t, md1.Member("sdf1").Spare)
+	assert.True(t, md1.Degraded())
+
+	md127 := arrays[2]
+	assert.Equal(t, "inactive", md127.State)
+	assert.Empty(t, md127.Level)
+	assert.Len(t, md127.Members, 1)
+
+	_, err = ParseMdstat(strings.NewReader("md0 : active raid1 sda1[0] bogus\n"))
+	assert.Error(t, err)
+
+	arrays, err = NewReader("testData/raspifix").Mdstat()
+	assert.NoError(t, err)
+	assert.Empty(t, arrays)
+}